@@ -60,3 +60,12 @@ func FromContext(ctx context.Context) *User {
 	}
 	return v.(*User)
 }
+
+// Roles returns the roles of the user in the context. it can be used with query.RLSApply.
+func Roles(ctx context.Context) []string {
+	user := FromContext(ctx)
+	if user == nil {
+		return nil
+	}
+	return []string{user.Role}
+}
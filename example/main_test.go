@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,8 @@ import (
 	"github.com/Stratoscale/swagger/example/models"
 	"github.com/Stratoscale/swagger/example/restapi"
 	"github.com/Stratoscale/swagger/example/restapi/operations/pet"
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/runtime/middleware"
 	"github.com/go-openapi/swag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -127,3 +130,137 @@ func TestHTTPHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestBindErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// bindErrorHandler is the handler that is passed to the restapi.Config
+		bindErrorHandler func(error) middleware.Responder
+		// wantCode is the expected response status code
+		wantCode int
+		// wantBody is the expected response body
+		wantBody func(t *testing.T, body string)
+	}{
+		{
+			name:     "default bind error handler",
+			wantCode: http.StatusBadRequest,
+			wantBody: func(t *testing.T, body string) {
+				var got restapi.BindError
+				require.Nil(t, json.Unmarshal([]byte(body), &got))
+				assert.Equal(t, http.StatusBadRequest, got.Code)
+				assert.Contains(t, got.Message, "petId")
+			},
+		},
+		{
+			name: "custom bind error handler",
+			bindErrorHandler: func(err error) middleware.Responder {
+				return middleware.ResponderFunc(func(rw http.ResponseWriter, p runtime.Producer) {
+					rw.WriteHeader(http.StatusBadRequest)
+					p.Produce(rw, map[string]string{"error": "bad request"})
+				})
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: func(t *testing.T, body string) {
+				assert.JSONEq(t, `{"error":"bad request"}`, body)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				resp  = httptest.NewRecorder()
+				req   = httptest.NewRequest(http.MethodGet, target+"/pets/kitty", nil)
+				mocks mocks
+			)
+
+			h, err := restapi.Handler(restapi.Config{
+				PetAPI:           &mocks.pet,
+				StoreAPI:         &mocks.store,
+				AuthToken:        auth.Token,
+				Authorizer:       auth.Request,
+				BindErrorHandler: tt.bindErrorHandler,
+				Logger:           t.Logf,
+			})
+			require.Nil(t, err)
+
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Cookie", `{"id":1,"role":"member"}`)
+
+			h.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantCode, resp.Code)
+			tt.wantBody(t, resp.Body.String())
+
+			// the business logic should not be called for malformed requests
+			mocks.assertExpectations(t)
+		})
+	}
+}
+
+func TestBindErrorHandlerContentType(t *testing.T) {
+	t.Parallel()
+
+	var (
+		resp  = httptest.NewRecorder()
+		req   = httptest.NewRequest(http.MethodPost, target+"/pets", bytes.NewBufferString(`{"name":"kitty"}`))
+		mocks mocks
+	)
+
+	h, err := restapi.Handler(restapi.Config{
+		PetAPI:     &mocks.pet,
+		StoreAPI:   &mocks.store,
+		AuthToken:  auth.Token,
+		Authorizer: auth.Request,
+		Logger:     t.Logf,
+	})
+	require.Nil(t, err)
+
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Cookie", `{"id":1,"role":"admin"}`)
+
+	h.ServeHTTP(resp, req)
+
+	// content negotiation errors keep their status code, and are not bind errors
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+	mocks.assertExpectations(t)
+}
+
+// flushRecorder is a response recorder that records the body on every flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	t.Parallel()
+
+	var (
+		resp  = &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		items = make(chan interface{})
+	)
+	go func() {
+		defer close(items)
+		for i := int64(1); i <= 3; i++ {
+			items <- &models.Pet{ID: i, Name: swag.String("kitty")}
+		}
+	}()
+
+	restapi.StreamJSONArrayResponder(http.StatusOK, items).WriteResponse(resp, runtime.JSONProducer())
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"id":1,"name":"kitty"},{"id":2,"name":"kitty"},{"id":3,"name":"kitty"}]`, resp.Body.String())
+	// each item should be flushed once it was written.
+	assert.Equal(t, []string{
+		`[{"id":1,"name":"kitty"}`,
+		`[{"id":1,"name":"kitty"},{"id":2,"name":"kitty"}`,
+		`[{"id":1,"name":"kitty"},{"id":2,"name":"kitty"},{"id":3,"name":"kitty"}`,
+	}, resp.flushes)
+}
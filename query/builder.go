@@ -3,16 +3,28 @@ package query
 import (
 	"bytes"
 	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/structs"
 	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/inflection"
 )
 
 // Wrapper is the interface that wraps the wrap method.
@@ -32,9 +44,24 @@ func (f WrapFn) Wrap(s string) string { return f(s) }
 // each field has one of the two: nopWrapper, or its own Wrapper implementation.
 var nopWrapper = WrapFn(func(s string) string { return s })
 
+// ValueWrapper is an optional alternative to the Wrapper interface, for custom types that
+// need to inspect or change the bound values of the expression (e.g. a subquery that uses
+// a different number of placeholders). if a type implements both, ValueWrapper is used.
+type ValueWrapper interface {
+	WrapValues(exp string, vals []interface{}) (string, []interface{})
+}
+
+// ValueWrapFn is a function type that implements the ValueWrapper interface.
+type ValueWrapFn func(string, []interface{}) (string, []interface{})
+
+// WrapValues is the function that implements the ValueWrapper interface.
+func (f ValueWrapFn) WrapValues(exp string, vals []interface{}) (string, []interface{}) {
+	return f(exp, vals)
+}
+
 // Searcher is the interface that wraps the Search method.
 // Models that want to support search, need to implement this interface.
-// if a "search" term is provided to the Parse method, the Builder will
+// if a search term (see Config.SearchParam) is provided to the Parse method, the Builder will
 // call the Search method with this value. the return value should be a search
 // query for this term + its arguments. (we let gorm handle the escaping).
 // If multiple search terms are provided, the Builder will use the SearchOperator to
@@ -43,9 +70,54 @@ type Searcher interface {
 	Search(term string) (exp string, vals []interface{})
 }
 
+// MultiSearcher is the interface that wraps the SearchAll method.
+// Models that implement this interface get all search terms in one call, and they control
+// how the terms are combined (e.g. a single ranked full text search). it's preferred over
+// the Searcher interface, and the SearchOperator is not used.
+type MultiSearcher interface {
+	SearchAll(terms []string) (exp string, vals []interface{})
+}
+
+// Enumerator is the interface that wraps the Enum method.
+// Field types (i.e: enum string types) that implement this interface, accept only
+// the returned values in their filters. the values are compared by their string
+// representation. for example:
+//
+//	func (Status) Enum() []interface{} { return []interface{}{StatusActive, StatusIdle} }
+type Enumerator interface {
+	Enum() []interface{}
+}
+
+// The codes of the parsing failures. see ParseError.Code.
+const (
+	// CodeInvalidValue - the value of the param is invalid, or has a wrong number of values.
+	CodeInvalidValue = "invalid_value"
+	// CodeUnknownField - the param, or the sort field, is unknown.
+	CodeUnknownField = "unknown_field"
+	// CodeOutOfRange - the value of the param is out of its allowed range.
+	CodeOutOfRange = "out_of_range"
+	// CodeNotAllowed - the param is valid, but it's not allowed in this query.
+	// e.g: a field outside the allow-list of ParseWith, or too many joins.
+	CodeNotAllowed = "not_allowed"
+)
+
+// ErrNoTaggedFields is returned by NewBuilder if Config.RequireTaggedFields is enabled,
+// and the model has no filter or sort fields.
+var ErrNoTaggedFields = errors.New("query: model has no filter or sort fields")
+
 // ParseError is a typed error created dynamically based on the parsing failure.
+// handlers can type-assert it to build a structured error response.
 type ParseError struct {
-	msg string
+	// Param is the name of the query param that failed the parsing.
+	Param string
+	// Code is the machine-readable code of the failure. e.g: CodeInvalidValue.
+	Code string
+	msg  string
+}
+
+// newParseError returns a new ParseError for the given param, with a formatted message.
+func newParseError(param, code, format string, args ...interface{}) *ParseError {
+	return &ParseError{Param: param, Code: code, msg: fmt.Sprintf(format, args...)}
 }
 
 // Error implements the error interface.
@@ -55,19 +127,309 @@ func (e ParseError) Error() string { return e.msg }
 // You should initialize it only once, and then use it in your http.Handler.
 type Builder struct {
 	*Config
-	searcher     Searcher
-	sortFields   map[string]bool
+	searcher    Searcher
+	multiSearch MultiSearcher
+	sortFields  map[string]bool
+	groupFields map[string]bool
+	// columns maps the names of the model fields (i.e: the sort and the group keys) to their
+	// columns. it holds all the fields of the model that are stored in a column, tagged or not.
+	columns map[string]string
+	// sortDesc are the sort fields with the "sortdesc" option.
+	sortDesc map[string]bool
+	// fields describe the struct fields of the model (and of the joined models) in their
+	// order, without their filter operators. see Fields.
+	fields []FieldInfo
+	// jsonFields are the fields with the "json" option. their filters are resolved by
+	// the param names, since the keys are not known in advance. see jsonFilter.
+	jsonFields   map[string]jsonField
 	filterFields map[string]filterField
 	selectFields []string
+	// multi-column filters resolved to their filter fields.
+	multiColumnFields map[string][]filterField
+	// the filter field of Config.CursorField. used for parsing the cursor param.
+	cursorField filterField
+	// sorted names of the filter and the multi-column filter params. used for
+	// emitting the clauses (and their values) in a deterministic order.
+	filterNames []string
+	// lowercased param names to their registered names.
+	// used only if CaseInsensitiveParams is enabled.
+	paramNames map[string]string
+	// the keys of Config.FieldParsers that are used by the filter fields. see checkFieldParsers.
+	usedParsers map[string]bool
 }
 
 type parseFn func(string) (interface{}, bool)
 
+// builderParseFn is a parser that depends on the config of the builder (i.e: TimeFormats or
+// BoolValues). the parsed fields of a model are cached and shared by builders with different
+// configs, and therefore, these parsers are bound to each builder. see Builder.bindFilter.
+type builderParseFn func(*Builder, string) (interface{}, bool)
+
+// on returns the parser bound to the given builder.
+func (fn builderParseFn) on(b *Builder) parseFn {
+	return func(s string) (interface{}, bool) { return fn(b, s) }
+}
+
+// fieldOptions are the options of a model field, that are shared by all its filters.
+type fieldOptions struct {
+	// name is the param name of the field. the filter params are prefixed by it.
+	name         string
+	column       string
+	computed     bool
+	typ          reflect.Type
+	wrap         WrapFn
+	splitOnComma bool
+	nullable     bool
+	// wrapValues is set for custom types that implement the ValueWrapper interface.
+	wrapValues ValueWrapFn
+	// multiAnd indicates that multiple values are combined with "AND". i.e: `query:"filter,multi=and"`.
+	multiAnd bool
+	// parse is an optional parser from Config.FieldParsers, that overrides the
+	// default parser of the field type.
+	parse parseFn
+	// bind is the parser of the field type, if it depends on the config of the builder.
+	bind builderParseFn
+	// enum holds the allowed values of fields that implement the Enumerator interface.
+	enum map[string]bool
+	// joins are the JOIN clauses that are required for filtering on a joined model field.
+	joins []string
+	// having indicates that the field is an aggregate expression. see Config.HavingFields.
+	having bool
+	// structField is the name of the struct field of the model. it's empty for fields of
+	// joined models.
+	structField string
+}
+
+// relation is the path to a joined model (i.e: a belongs-to association). the zero value
+// stands for the model itself.
+type relation struct {
+	// prefix of the param names of the joined model fields. e.g: "owner.".
+	prefix string
+	// table name of the joined model.
+	table string
+	// joins are the JOIN clauses from the model to the joined model.
+	joins []string
+}
+
 type filterField struct {
-	exp          string
+	// field is the name of the filter without the operator. e.g: "age" for "age_gt".
+	field string
+	// op is the operator of the filter. empty for the bare column name.
+	op string
+	// column is the column name that is used as the first operand of the format.
+	column string
+	// computed indicates that the column is not a column of the model, but a server-defined
+	// expression or a qualified column of a joined model. computed columns are not rewritten.
+	computed bool
+	// joins are the JOIN clauses that are required by the filter.
+	joins []string
+	// having indicates that the filter is added to the HAVING clause, instead of WHERE.
+	having bool
+	// structField is the name of the struct field of the model. e.g: "CreatedAt".
+	structField string
+	// typ is the type of the model field.
+	typ reflect.Type
+	// format of the expression. for example: "%s = ?".
+	format string
+	// anyFormat is an optional format for combining multiple values in one expression,
+	// instead of concatenating them with "OR". for example: "%s LIKE ANY(ARRAY[%s])".
+	anyFormat    string
 	parse        parseFn
 	wrap         WrapFn
 	splitOnComma bool
+	// bind returns the parser of the filter for the given builder. it's set if the parser
+	// depends on the config of the builder, and it's used for binding the cached filters.
+	bind func(*Builder) parseFn
+	// wrapValues wraps the final clause of the filter, with its values. see ValueWrapper.
+	wrapValues ValueWrapFn
+	// multiAnd indicates that multiple values are combined with "AND", instead of "OR".
+	// each value is wrapped separately. see the "multi" option.
+	multiAnd bool
+}
+
+// combine combines the expressions of multiple values, and wraps the result.
+func (f filterField) combine(exps []string) string {
+	if f.multiAnd && len(exps) > 1 {
+		for i := range exps {
+			exps[i] = f.wrap(exps[i])
+		}
+		return "(" + strings.Join(exps, " AND ") + ")"
+	}
+	exp := strings.Join(exps, " OR ")
+	if len(exps) > 1 {
+		exp = "(" + exp + ")"
+	}
+	return f.wrap(exp)
+}
+
+// exp returns the filter expression. the column name passes through the given
+// rewrite function before formatting the expression.
+func (f filterField) exp(rewrite func(string) string) string {
+	return fmt.Sprintf(f.format, f.rewriteColumn(rewrite))
+}
+
+// rewriteColumn returns the column after passing it through the given rewrite function.
+func (f filterField) rewriteColumn(rewrite func(string) string) string {
+	if f.computed {
+		return f.column
+	}
+	return rewrite(f.column)
+}
+
+// singleValue reports whether the filter expression binds exactly one parsed value
+// for each argument. it is false for operators with a custom clause builder.
+func (f filterField) singleValue() bool {
+	switch f.op {
+	case opIn, opNotIn, opNull, opBetween:
+		return false
+	}
+	return true
+}
+
+// checkArity validates the number of values in the given param arguments, according
+// to the arity of the filter operator. see operatorArity.
+func (f filterField) checkArity(name string, args []string) error {
+	a, ok := operatorArity[f.op]
+	if !ok {
+		a = arity{min: 1, max: 1}
+	}
+	if a.single && len(args) != 1 {
+		return newParseError(name, CodeInvalidValue, "expect one value for key '%s', got %d", name, len(args))
+	}
+	for _, arg := range args {
+		// all operators require a value.
+		if arg == "" {
+			return newParseError(name, CodeInvalidValue, "invalid parameter for key '%s'", name)
+		}
+		n := 1
+		if a.max != 1 {
+			n = len(strings.Split(arg, ","))
+		}
+		switch {
+		case a.min == a.max && n != a.min:
+			return newParseError(name, CodeInvalidValue, "expect %d value(s) for key '%s', got %d", a.min, name, n)
+		case n < a.min:
+			return newParseError(name, CodeInvalidValue, "expect at least %d value(s) for key '%s', got %d", a.min, name, n)
+		case a.max != -1 && n > a.max:
+			return newParseError(name, CodeInvalidValue, "expect at most %d value(s) for key '%s', got %d", a.max, name, n)
+		}
+	}
+	return nil
+}
+
+// clause builds the filter clause for the given param arguments.
+func (f filterField) clause(name string, args []string, rewrite func(string) string) (Clause, error) {
+	if f.splitOnComma && len(args) == 1 && strings.Contains(args[0], ",") {
+		args = strings.Split(args[0], ",")
+	}
+	// there are two expression formats:
+	// 1. "KEY = VAL"                     - when only one argument is given.
+	// 2. "(KEY = VAL OR KEY = VAL2 ...)" - when multiple values are given.
+	// we use "=" in this example, but it could be any other operator.
+	var (
+		expArgs = make([]string, 0, len(args))
+		vals    = make([]interface{}, 0, len(args))
+	)
+	for _, arg := range args {
+		v, ok := f.parse(arg)
+		if !ok {
+			return Clause{}, newParseError(name, CodeInvalidValue, "invalid parameter for key '%s'", name)
+		}
+		vals = append(vals, v)
+		// collect expressions.
+		expArgs = append(expArgs, f.exp(rewrite))
+	}
+	// if there's more than one argument, use the "ANY" format if the field
+	// has one, or concatenate the expressions with "OR" (or "AND").
+	if len(args) > 1 && f.anyFormat != "" && !f.multiAnd {
+		exp := fmt.Sprintf(f.anyFormat, f.rewriteColumn(rewrite), strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", "))
+		return Clause{Exp: f.wrap(exp), Vals: vals}, nil
+	}
+	return Clause{Exp: f.combine(expArgs), Vals: vals}, nil
+}
+
+// nullClause builds the clause of the "null" operator. it gets one boolean argument, and
+// doesn't bind any value. for example: "deleted_at_null=true" is "deleted_at IS NULL".
+// the argument is parsed with the given boolean parser. see Config.BoolValues.
+func (f filterField) nullClause(name string, args []string, parseBool parseFn, rewrite func(string) string) (Clause, error) {
+	v, ok := parseBool(args[0])
+	if !ok {
+		return Clause{}, newParseError(name, CodeInvalidValue, "invalid parameter for key '%s'", name)
+	}
+	isNull, _ := strconv.ParseBool(v.(string))
+	format := "%s IS NOT NULL"
+	if isNull {
+		format = "%s IS NULL"
+	}
+	return Clause{Exp: f.wrap(fmt.Sprintf(format, f.rewriteColumn(rewrite)))}, nil
+}
+
+// betweenClause builds the clause of the "between" operator. each argument holds the two
+// comma-separated endpoints of the range. for example: "age_between=10,20" is "age BETWEEN ? AND ?".
+// if there's more than one argument, the ranges are concatenated with "OR" (or "AND").
+func (f filterField) betweenClause(name string, args []string, rewrite func(string) string) (Clause, error) {
+	var (
+		expArgs = make([]string, 0, len(args))
+		vals    = make([]interface{}, 0, 2*len(args))
+	)
+	for _, arg := range args {
+		for _, s := range strings.Split(arg, ",") {
+			v, ok := f.parse(s)
+			if !ok {
+				return Clause{}, newParseError(name, CodeInvalidValue, "invalid parameter for key '%s'", name)
+			}
+			vals = append(vals, v)
+		}
+		expArgs = append(expArgs, f.exp(rewrite))
+	}
+	return Clause{Exp: f.combine(expArgs), Vals: vals}, nil
+}
+
+// listClause builds the clause of list operators (i.e: "in"). the arguments are always
+// split on commas, and the parsed values are bound as one slice argument, unless expand is
+// set. for example: "id_in=1,2,3" is "id IN (?)" with []interface{}{1, 2, 3}.
+func (f filterField) listClause(name string, args []string, expand bool, rewrite func(string) string) (Clause, error) {
+	var list []interface{}
+	for _, arg := range args {
+		for _, s := range strings.Split(arg, ",") {
+			v, ok := f.parse(s)
+			if !ok {
+				return Clause{}, newParseError(name, CodeInvalidValue, "invalid parameter for key '%s'", name)
+			}
+			list = append(list, v)
+		}
+	}
+	// numbered placeholders can not be expanded by gorm, and therefore, the list gets a
+	// placeholder for each value. i.e: "id IN (?, ?)".
+	if expand {
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ")
+		exp := fmt.Sprintf(strings.Replace(f.format, "?", marks, 1), f.rewriteColumn(rewrite))
+		return Clause{Exp: f.wrap(exp), Vals: list}, nil
+	}
+	return Clause{Exp: f.wrap(f.exp(rewrite)), Vals: []interface{}{f.typedList(list)}}, nil
+}
+
+// typedList converts the given values to a slice of the field type (e.g. []int64 for an int64
+// field), since some drivers handle typed slices better than []interface{}. values that can not
+// be converted to the field type (e.g. from a custom parser) are returned as is.
+func (f filterField) typedList(list []interface{}) interface{} {
+	typ := f.typ
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || len(list) == 0 {
+		return list
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(typ), len(list), len(list))
+	for i, v := range list {
+		rv := reflect.ValueOf(v)
+		// numbers are convertible to strings (as runes), but they are not the same value.
+		if !rv.IsValid() || !rv.Type().ConvertibleTo(typ) || (rv.Kind() == reflect.String) != (typ.Kind() == reflect.String) {
+			return list
+		}
+		slice.Index(i).Set(rv.Convert(typ))
+	}
+	return slice.Interface()
 }
 
 // NewBuilder initialize a Builder and parse the passing Model that will be used in
@@ -82,14 +444,24 @@ func NewBuilder(c *Config) (*Builder, error) {
 	}
 
 	b := &Builder{
-		Config:       c,
-		sortFields:   make(map[string]bool),
-		filterFields: make(map[string]filterField),
+		Config:            c,
+		sortFields:        make(map[string]bool),
+		groupFields:       make(map[string]bool),
+		columns:           make(map[string]string),
+		sortDesc:          make(map[string]bool),
+		jsonFields:        make(map[string]jsonField),
+		filterFields:      make(map[string]filterField),
+		multiColumnFields: make(map[string][]filterField),
 	}
 	if searcher, ok := c.Model.(Searcher); ok {
 		b.searcher = searcher
 	}
-	b.init()
+	if searcher, ok := c.Model.(MultiSearcher); ok {
+		b.multiSearch = searcher
+	}
+	if err := b.init(); err != nil {
+		return nil, err
+	}
 	return b, nil
 }
 
@@ -102,76 +474,1122 @@ func MustNewBuilder(c *Config) *Builder {
 	return b
 }
 
-// Move typ to config and comment that init should be called only once.
-func (b *Builder) init() {
-	// build the sort-fields and filter-fields data structures.
+// Clone returns a copy of the builder with a copy of its config, that can be adjusted
+// without affecting the original builder. i.e: a different DefaultLimit or IgnoreSort for
+// an endpoint. The fields of the model are not parsed again, and therefore, only the options
+// that are used by the Parse method take effect. The model itself (and the maps and slices
+// of the config) are shared by both builders, and must not be mutated.
+func (b *Builder) Clone() *Builder {
+	c := *b.Config
+	clone := *b
+	clone.Config = &c
+	clone.setModelFields(b.modelFields())
+	clone.multiColumnFields = make(map[string][]filterField, len(b.multiColumnFields))
+	for name, fields := range b.multiColumnFields {
+		clone.multiColumnFields[name] = make([]filterField, len(fields))
+		for i, f := range fields {
+			clone.multiColumnFields[name][i] = clone.bindFilter(f)
+		}
+	}
+	clone.cursorField = clone.bindFilter(b.cursorField)
+	clone.filterNames = append([]string(nil), b.filterNames...)
+	if b.paramNames != nil {
+		clone.paramNames = make(map[string]string, len(b.paramNames))
+		for k, v := range b.paramNames {
+			clone.paramNames[k] = v
+		}
+	}
+	return &clone
+}
+
+// bindFilter returns the given filter with its parser bound to the builder, if it depends
+// on the config of the builder.
+func (b *Builder) bindFilter(f filterField) filterField {
+	if f.bind != nil {
+		f.parse = f.bind(b)
+	}
+	return f
+}
+
+// copyBoolMap returns a copy of the given map.
+func copyBoolMap(m map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// copyStringMap returns a copy of the given map.
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// parseFields parses the fields of a model. the struct is traversed level by level, and
+// a field that was already seen in a shallower level (or earlier in the same level)
+// shadows the embedded fields with the same name. i.e. outer wins.
+func (b *Builder) parseFields(fields []*structs.Field, rel relation) {
 	l := list.New()
-	l.PushFront(structs.Fields(b.Model))
+	l.PushBack(fields)
+	seen := make(map[string]bool)
 	for l.Len() > 0 {
 		fields := l.Remove(l.Front())
 		for _, field := range fields.([]*structs.Field) {
+			if seen[field.Name()] {
+				continue
+			}
+			seen[field.Name()] = true
 			if field.IsEmbedded() && field.Kind() == reflect.Struct {
-				l.PushFront(field.Fields())
+				l.PushBack(field.Fields())
 				continue
 			}
-			b.parseField(field)
+			// embedded pointers are walked by their type, since they are nil in zero-value models.
+			if field.IsEmbedded() && field.Kind() == reflect.Ptr {
+				if typ := reflect.TypeOf(field.Value()).Elem(); typ.Kind() == reflect.Struct {
+					l.PushBack(structs.Fields(reflect.New(typ).Interface()))
+					continue
+				}
+			}
+			b.parseField(field, rel)
+		}
+	}
+}
+
+// fieldsCache holds the fields of the parsed models by their fieldsKey, and it's used for
+// skipping the reflection walk of models that were already parsed with the same options.
+var fieldsCache sync.Map
+
+// fieldsKey is the model type, and the config options that affect the parsing of its fields.
+type fieldsKey struct {
+	typ                         reflect.Type
+	tagName                     string
+	separator                   string
+	joinSeparator               string
+	dialect                     string
+	explicitSelect              bool
+	onlySelectNonDetailedFields bool
+	nullSafeNeq                 bool
+	likeAnyArray                bool
+	splitOnComma                bool
+	disabledOperators           string
+}
+
+// modelFields are the fields of a model, that are registered by parseFields.
+type modelFields struct {
+	sortFields   map[string]bool
+	groupFields  map[string]bool
+	columns      map[string]string
+	sortDesc     map[string]bool
+	fields       []FieldInfo
+	jsonFields   map[string]jsonField
+	filterFields map[string]filterField
+	selectFields []string
+}
+
+// parseModel parses the fields of the model, or loads them from the cache. builders with
+// field parsers are not cached, since their parsers can not be compared.
+func (b *Builder) parseModel() {
+	if len(b.FieldParsers) > 0 {
+		b.usedParsers = make(map[string]bool, len(b.FieldParsers))
+		b.parseFields(structs.Fields(b.Model), relation{})
+		return
+	}
+	key := fieldsKey{
+		typ:                         reflect.TypeOf(b.Model),
+		tagName:                     b.TagName,
+		separator:                   b.Separator,
+		joinSeparator:               b.JoinSeparator,
+		dialect:                     b.Dialect,
+		explicitSelect:              b.ExplicitSelect,
+		onlySelectNonDetailedFields: b.OnlySelectNonDetailedFields,
+		nullSafeNeq:                 b.NullSafeNeq,
+		likeAnyArray:                b.LikeAnyArray,
+		splitOnComma:                b.SplitOnComma,
+		disabledOperators:           strings.Join(b.DisabledOperators, ","),
+	}
+	if fields, ok := fieldsCache.Load(key); ok {
+		b.setModelFields(fields.(*modelFields))
+		return
+	}
+	b.parseFields(structs.Fields(b.Model), relation{})
+	fieldsCache.Store(key, b.modelFields())
+}
+
+// modelFields returns a copy of the fields of the builder.
+func (b *Builder) modelFields() *modelFields {
+	fields := &modelFields{
+		sortFields:   copyBoolMap(b.sortFields),
+		groupFields:  copyBoolMap(b.groupFields),
+		columns:      copyStringMap(b.columns),
+		sortDesc:     copyBoolMap(b.sortDesc),
+		fields:       append([]FieldInfo(nil), b.fields...),
+		jsonFields:   make(map[string]jsonField, len(b.jsonFields)),
+		filterFields: make(map[string]filterField, len(b.filterFields)),
+		selectFields: append([]string(nil), b.selectFields...),
+	}
+	for name, f := range b.jsonFields {
+		fields.jsonFields[name] = f
+	}
+	// the parsers that are bound to the builder are not copied. see Builder.bindFilter.
+	for name, f := range b.filterFields {
+		if f.bind != nil {
+			f.parse = nil
+		}
+		fields.filterFields[name] = f
+	}
+	return fields
+}
+
+// setModelFields sets the builder fields to a copy of the given fields.
+func (b *Builder) setModelFields(fields *modelFields) {
+	b.sortFields = copyBoolMap(fields.sortFields)
+	b.groupFields = copyBoolMap(fields.groupFields)
+	b.columns = copyStringMap(fields.columns)
+	b.sortDesc = copyBoolMap(fields.sortDesc)
+	b.fields = append([]FieldInfo(nil), fields.fields...)
+	b.jsonFields = make(map[string]jsonField, len(fields.jsonFields))
+	for name, f := range fields.jsonFields {
+		b.jsonFields[name] = f
+	}
+	b.filterFields = make(map[string]filterField, len(fields.filterFields))
+	for name, f := range fields.filterFields {
+		b.filterFields[name] = b.bindFilter(f)
+	}
+	b.selectFields = append([]string(nil), fields.selectFields...)
+}
+
+// Move typ to config and comment that init should be called only once.
+func (b *Builder) init() error {
+	// build the sort-fields and filter-fields data structures.
+	b.parseModel()
+	if b.RequireTaggedFields && len(b.filterFields) == 0 && len(b.jsonFields) == 0 && len(b.sortFields) == 0 {
+		return ErrNoTaggedFields
+	}
+	if err := b.checkFieldParsers(); err != nil {
+		return err
+	}
+	// computed filters are registered with the string operators, and are formatted
+	// as a parenthesized expression. e.g: "(first_name || ' ' || last_name) LIKE ?".
+	for name, exp := range b.ComputedFilters {
+		if _, ok := b.filterFields[name]; ok {
+			return fmt.Errorf("query: computed filter '%s' collides with a filter", name)
+		}
+		b.addStringField(fieldOptions{
+			name:     name,
+			column:   "(" + exp + ")",
+			computed: true,
+			typ:      reflect.TypeOf(exp),
+			wrap:     nopWrapper,
+		})
+	}
+	// having fields are registered with the numeric operators, and their clauses are
+	// added to the HAVING clause of the query. e.g: "COUNT(*) > ?".
+	for name, exp := range b.HavingFields {
+		if _, ok := b.filterFields[name]; ok {
+			return fmt.Errorf("query: having field '%s' collides with a filter", name)
+		}
+		b.addFilterFieldsForNumericFields(fieldOptions{
+			name:     name,
+			column:   exp,
+			computed: true,
+			typ:      reflect.TypeOf(float64(0)),
+			wrap:     nopWrapper,
+			having:   true,
+		}, parseFloat)
+	}
+	// resolve the multi-column filters to the registered filter fields.
+	for name, columns := range b.MultiColumnFilters {
+		if _, ok := b.filterFields[name]; ok {
+			return fmt.Errorf("query: multi-column filter '%s' collides with a filter", name)
+		}
+		fields := make([]filterField, 0, len(columns))
+		for _, c := range columns {
+			key := c.Column
+			if c.Op != "" {
+				key += b.Separator + c.Op
+			}
+			field, ok := b.filterFields[key]
+			if !ok || !field.singleValue() {
+				return fmt.Errorf("query: multi-column filter '%s' references unknown filter '%s'", name, key)
+			}
+			fields = append(fields, field)
+		}
+		b.multiColumnFields[name] = fields
+	}
+	for name := range b.SortExpressions {
+		if _, ok := b.ComputedSorts[name]; ok {
+			return fmt.Errorf("query: sort expression '%s' is also a computed sort", name)
+		}
+	}
+	for name, nulls := range b.SortNulls {
+		if _, ok := b.computedSort(name); !ok && !b.sortFields[name] {
+			return fmt.Errorf("query: nulls order of unknown sort field '%s'", name)
+		}
+		if nulls != NullsFirst && nulls != NullsLast {
+			return fmt.Errorf("query: invalid nulls order '%s' for sort field '%s'", nulls, name)
+		}
+	}
+	// the default sort is used as is, and therefore, its columns are validated here. they
+	// are not required to be sortable fields, and qualified columns and expressions are
+	// not validated.
+	columns := make(map[string]bool, len(b.columns))
+	for _, column := range b.columns {
+		columns[column] = true
+	}
+	for _, f := range parseSortFields(b.DefaultSort) {
+		if !columns[f.Column] && !strings.ContainsAny(f.Column, ".(") {
+			return fmt.Errorf("query: default sort of unknown column '%s'", f.Column)
+		}
+	}
+	// the search param of a Searcher model can not be used as a filter as well.
+	if b.searchable() {
+		_, isFilter := b.filterFields[b.SearchParam]
+		_, isMultiColumn := b.multiColumnFields[b.SearchParam]
+		if isFilter || isMultiColumn {
+			return fmt.Errorf("query: filter '%s' collides with the search param of the model", b.SearchParam)
+		}
+	}
+	for _, name := range b.ReservedParams() {
+		_, isFilter := b.filterFields[name]
+		_, isMultiColumn := b.multiColumnFields[name]
+		if isFilter || isMultiColumn {
+			return fmt.Errorf("query: filter '%s' collides with a reserved param", name)
 		}
 	}
+	if b.CursorField != "" {
+		field, ok := b.filterFields[b.CursorField]
+		if !ok {
+			field, ok = b.filterFields[b.CursorField+b.Separator+opEqual]
+		}
+		if !ok {
+			return fmt.Errorf("query: cursor field '%s' is not a filter field", b.CursorField)
+		}
+		b.cursorField = field
+	}
+	for name := range b.filterFields {
+		b.filterNames = append(b.filterNames, name)
+	}
+	for name := range b.multiColumnFields {
+		b.filterNames = append(b.filterNames, name)
+	}
+	sort.Strings(b.filterNames)
+	if b.CaseInsensitiveParams {
+		b.paramNames = make(map[string]string)
+		for _, name := range b.controlParams() {
+			b.paramNames[strings.ToLower(name)] = name
+		}
+		for name := range b.filterFields {
+			b.paramNames[strings.ToLower(name)] = name
+		}
+	}
+	return nil
+}
+
+// checkFieldParsers validates that the keys of the field parsers are columns of the filter
+// fields, since a parser of an unknown column is never called.
+func (b *Builder) checkFieldParsers() error {
+	for name := range b.FieldParsers {
+		if !b.usedParsers[name] {
+			return fmt.Errorf("query: field parser of unknown column '%s'", name)
+		}
+	}
+	return nil
 }
 
 // Parse validates and parses the input params and return back a *DBQuery.
 // It's safe to call it from multiple goroutines concurrently.
 func (b *Builder) Parse(params url.Values) (*DBQuery, error) {
+	return b.ParseContext(context.Background(), params)
+}
+
+// ParseContext is like Parse, but the parsing stops with the context error if the context is
+// done. It's useful for bounding the parsing time of huge query strings. for example:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+//	defer cancel()
+//	q, err := b.ParseContext(ctx, r.URL.Query())
+func (b *Builder) ParseContext(ctx context.Context, params url.Values) (*DBQuery, error) {
+	return b.parse(ctx, params, nil)
+}
+
+// ParseWithColumnRewriter is like Parse, but each column that is emitted to the query
+// (in the filter, sort and select expressions) passes through the given rewrite function.
+// For example, for routing the query to a per-request schema: "tenant_x.pets.name".
+// Note that expressions that are returned from Wrapper and Searcher implementations
+// are not rewritten.
+func (b *Builder) ParseWithColumnRewriter(params url.Values, rewrite func(string) string) (*DBQuery, error) {
+	return b.parse(context.Background(), params, rewrite)
+}
+
+// ParseWith is like Parse, but only the filter and sort fields in the given allow-list are
+// honored for this call. a filter or sort param of another field is rejected with a ParseError.
+// the names in the list are the field names without the operators (e.g. "age" allows "age"
+// and "age_gt"), the names of the multi-column filters and the names of the computed sorts.
+// It's useful for exposing the same model to different roles without creating more builders.
+func (b *Builder) ParseWith(params url.Values, allowed []string) (*DBQuery, error) {
+	if b.CaseInsensitiveParams {
+		params = b.canonicalParams(params)
+	}
+	set := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		set[name] = true
+	}
+	if err := b.checkAllowed(params, set); err != nil {
+		return nil, err
+	}
+	return b.parse(context.Background(), params, nil)
+}
+
+// checkAllowed validates that the filter and sort params refer only to the allowed fields.
+func (b *Builder) checkAllowed(params url.Values, allowed map[string]bool) error {
+	for _, name := range b.usedFilters(params) {
+		field := name
+		if f, ok := b.lookupFilter(name); ok {
+			field = f.field
+		}
+		if !allowed[field] {
+			return newParseError(name, CodeNotAllowed, "filter '%s' is not allowed", name)
+		}
+	}
+	if b.IgnoreSort {
+		return nil
+	}
+	for _, field := range params[b.SortParam] {
+		if field != "" {
+			if _, ok := sortDirections[field[0]]; ok {
+				field = field[1:]
+			}
+		}
+		if field != "" && !allowed[field] {
+			return newParseError(b.SortParam, CodeNotAllowed, "sort parameter '%s' is not allowed", field)
+		}
+	}
+	return nil
+}
+
+// usedFilters returns the names of the registered filters that are used by the params,
+// including the filters of the OR group terms.
+func (b *Builder) usedFilters(params url.Values) []string {
+	var names []string
+	for _, name := range b.filterNames {
+		if _, ok := params[name]; ok {
+			names = append(names, name)
+		}
+	}
+	names = append(names, b.jsonParams(params)...)
+	if b.OrParam == "" {
+		return names
+	}
+	for _, term := range params[b.OrParam] {
+		name := strings.SplitN(term, ":", 2)[0]
+		_, isFilter := b.lookupFilter(name)
+		_, isMultiColumn := b.multiColumnFields[name]
+		if isFilter || isMultiColumn {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// usesJoins reports whether one of the filters that are used by the params joins other tables.
+func (b *Builder) usesJoins(params url.Values) bool {
+	for _, name := range b.usedFilters(params) {
+		for _, f := range b.multiColumnFields[name] {
+			if len(f.joins) > 0 {
+				return true
+			}
+		}
+		if f, ok := b.lookupFilter(name); ok && len(f.joins) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifyColumns returns a rewrite function that prefixes the columns with the table of the
+// model, after passing them through the given rewrite function (that may be nil). columns that
+// are already qualified, and expressions, are left as is.
+func (b *Builder) qualifyColumns(rewrite func(string) string) func(string) string {
+	table := b.tableName(reflect.TypeOf(b.Model))
+	return func(column string) string {
+		if rewrite != nil {
+			column = rewrite(column)
+		}
+		if column == "" || strings.ContainsAny(column, ".( ") {
+			return column
+		}
+		return table + "." + column
+	}
+}
+
+// parse is the implementation of the Parse methods. rewrite may be nil.
+func (b *Builder) parse(ctx context.Context, params url.Values, rewrite func(string) string) (*DBQuery, error) {
+	if b.CaseInsensitiveParams {
+		params = b.canonicalParams(params)
+	}
+	q, err := b.build(ctx, params, rewrite)
+	if err != nil {
+		return nil, err
+	}
+	if b.ColumnUsageHook != nil {
+		if rewrite == nil {
+			rewrite = nopWrapper
+		}
+		b.ColumnUsageHook(b.columnUsage(params, q, rewrite))
+	}
+	return q, nil
+}
+
+// ValidateParams validates the params like Parse, without returning the query. a nil error
+// means that Parse succeeds with the given params. It's useful for rejecting invalid requests
+// before they are routed to their handlers (e.g. in a gateway). The ColumnUsageHook is not
+// called for the validated params.
+func (b *Builder) ValidateParams(params url.Values) error {
+	if b.CaseInsensitiveParams {
+		params = b.canonicalParams(params)
+	}
+	_, err := b.build(context.Background(), params, nil)
+	return err
+}
+
+// build builds the query from the given params. the params are expected to be canonical.
+func (b *Builder) build(ctx context.Context, params url.Values, rewrite func(string) string) (*DBQuery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if b.StrictParams {
+		if unknown := b.UnknownParams(params); len(unknown) > 0 {
+			return nil, newParseError(unknown[0], CodeUnknownField, "unknown parameter '%s'", unknown[0])
+		}
+	}
+	// the columns of the model are qualified with its table when the query joins other
+	// tables, since they may be ambiguous with the columns of the joined tables.
+	if b.usesJoins(params) {
+		rewrite = b.qualifyColumns(rewrite)
+	}
 	q := &DBQuery{
 		Sort:   b.DefaultSort,
 		Limit:  b.DefaultLimit,
 		Select: strings.Join(b.selectFields[:], ","),
 	}
+	if rewrite != nil {
+		q.Sort = rewriteSort(b.DefaultSort, rewrite)
+		q.Select = strings.Join(rewriteAll(b.selectFields, rewrite), ",")
+	} else {
+		rewrite = nopWrapper
+	}
+	q.Pagination.DefaultLimit, q.Pagination.DefaultOffset = true, true
 	// parse and validate limit.
 	if v := params.Get(b.LimitParam); v != "" {
-		n, err := parseNumber(b.LimitParam, v, 0, b.LimitMaxValue)
+		max := b.LimitMaxValue
+		if b.ClampLimit {
+			max = -1
+		}
+		n, err := parseNumber(b.LimitParam, v, 0, max)
 		if err != nil {
 			return nil, err
 		}
+		if n > b.LimitMaxValue {
+			n, q.LimitClamped = b.LimitMaxValue, true
+		}
 		q.Limit = n
+		q.Pagination.DefaultLimit = false
+		// an explicit zero limit selects all rows. see Config.AllowUnlimited.
+		q.Pagination.Unlimited = n == 0 && b.AllowUnlimited
 	}
 	// parse and validate offset.
 	if v := params.Get(b.OffsetParam); v != "" {
-		n, err := parseNumber(b.OffsetParam, v, 0, -1)
+		max := -1
+		if b.OffsetMaxValue > 0 {
+			max = b.OffsetMaxValue
+		}
+		n, err := parseNumber(b.OffsetParam, v, 0, max)
 		if err != nil {
 			return nil, err
 		}
 		q.Offset = n
+		q.Pagination.DefaultOffset = false
 	}
+	q.Pagination.Limit, q.Pagination.Offset = q.Limit, q.Offset
 	// parse and validate sort parameters.
+	q.SortFields = parseSortFields(q.Sort)
 	if sortFields, ok := params[b.SortParam]; !b.IgnoreSort && ok {
-		sortExp, err := b.parseSort(sortFields)
+		if err := b.parseSort(ctx, q, sortFields, rewrite); err != nil {
+			return nil, err
+		}
+	}
+	// parse and validate group parameters.
+	if err := b.parseGroup(q, params[b.GroupParam], rewrite); err != nil {
+		return nil, err
+	}
+	// the tie-breaker is not added to grouped queries, since it's not one of their columns.
+	if b.StableSortKey != "" && q.Sort != "" && q.GroupBy == "" {
+		b.stableSort(q, rewrite)
+	}
+	// the distinct param applies to the select of the query, including the group columns.
+	if v := params.Get(distinctParam); v != "" && b.AllowDistinct {
+		distinct, err := parseOptOut(distinctParam, v)
 		if err != nil {
 			return nil, err
 		}
-		q.Sort = sortExp
+		if distinct {
+			q.Select = "DISTINCT " + defaultSelect(q.Select)
+		}
+	}
+	// keyset pagination. the cursor replaces the offset and the sort of the query. the
+	// "before" cursor pages backward, and therefore the rows are in descending order.
+	if b.CursorField != "" {
+		q.cursorField = b.cursorField.structField
+		after, before := params.Get(b.CursorParam), params.Get(b.BeforeParam)
+		if after != "" && before != "" {
+			return nil, newParseError(b.BeforeParam, CodeNotAllowed, "key '%s' can not be used with '%s'", b.CursorParam, b.BeforeParam)
+		}
+		param, v := b.CursorParam, after
+		if before != "" {
+			param, v = b.BeforeParam, before
+		}
+		if v != "" {
+			if _, ok := params[b.SortParam]; ok {
+				return nil, newParseError(param, CodeNotAllowed, "key '%s' can not be used with '%s'", b.SortParam, param)
+			}
+			value, ok := b.cursorField.parse(v)
+			if !ok {
+				return nil, newParseError(param, CodeInvalidValue, "invalid value('%s') for key '%s'", v, param)
+			}
+			column := b.cursorField.rewriteColumn(rewrite)
+			q.Cursor = &Cursor{Column: column, Value: value, Before: before != ""}
+			q.Offset, q.Pagination.Offset = 0, 0
+			q.Sort, q.SortVal = column+" asc", nil
+			if q.Cursor.Before {
+				q.Sort = column + " desc"
+			}
+			q.SortFields = []SortField{{Column: column, Desc: q.Cursor.Before}}
+		}
 	}
 	// parse and validate conditions and filter parameters.
-	exp, val, err := b.parseFilter(params)
+	clauses, joins, err := b.parseFilter(ctx, params, rewrite)
+	if err != nil {
+		return nil, err
+	}
+	q.Joins = joins
+	q.AppliedFilters = b.filterColumns(params, rewrite)
+	sort.Strings(q.AppliedFilters)
+	for _, c := range clauses {
+		q.And(c.Exp, c.Vals...)
+	}
+	having, err := b.parseHaving(params, rewrite)
 	if err != nil {
 		return nil, err
 	}
-	q.CondExp, q.CondVal = exp, val
+	for _, c := range having {
+		if q.HavingExp != "" {
+			q.HavingExp += " AND "
+		}
+		q.HavingExp += c.Exp
+		q.HavingVal = append(q.HavingVal, c.Vals...)
+	}
+	// add the base conditions that were not opted-out.
+	for _, c := range b.BaseConditions {
+		if c.OptOutParam != "" {
+			optOut, err := parseOptOut(c.OptOutParam, params.Get(c.OptOutParam))
+			if err != nil {
+				return nil, err
+			}
+			if optOut {
+				continue
+			}
+		}
+		q.And(c.Exp, c.Vals...)
+	}
 	// model implements the searcher interface.
-	if terms, ok := params[searchParam]; ok && b.searcher != nil {
+	if terms, ok := params[b.SearchParam]; ok && b.searchable() {
 		exp, vals := b.parseSearch(terms)
 		q.And(exp, vals...)
 	}
+	if b.Placeholder == PlaceholderDollar {
+		numberPlaceholders(q)
+	}
 	return q, nil
 }
 
+// columnUsage returns the distinct columns that are filtered, sorted or grouped by the query, in
+// their order in the query. computed filters and computed sorts are not columns, and
+// therefore, they are not included.
+func (b *Builder) columnUsage(params url.Values, q *DBQuery, rewrite func(string) string) []string {
+	var (
+		columns = b.filterColumns(params, rewrite)
+		seen    = make(map[string]bool)
+	)
+	for _, column := range columns {
+		seen[column] = true
+	}
+	add := func(column string) {
+		if !seen[column] && !strings.HasPrefix(column, "(") {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, f := range q.SortFields {
+		if _, ok := b.computedSort(f.Column); !ok {
+			add(f.Column)
+		}
+	}
+	if q.GroupBy != "" {
+		for _, column := range strings.Split(q.GroupBy, ", ") {
+			add(column)
+		}
+	}
+	return columns
+}
+
+// filterColumns returns the distinct columns that are filtered by the params, in their order
+// in the query. computed filters and having fields are not columns, and they are not included.
+func (b *Builder) filterColumns(params url.Values, rewrite func(string) string) []string {
+	var (
+		columns []string
+		seen    = make(map[string]bool)
+	)
+	add := func(column string) {
+		if !seen[column] && !strings.HasPrefix(column, "(") {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, name := range b.usedFilters(params) {
+		for _, f := range b.multiColumnFields[name] {
+			add(f.rewriteColumn(rewrite))
+		}
+		if f, ok := b.lookupFilter(name); ok && !f.having {
+			add(f.rewriteColumn(rewrite))
+		}
+	}
+	return columns
+}
+
+// numberPlaceholders replaces the "?" placeholders of the query expressions with
+// ordinal placeholders ("$1", "$2", ...). the sort values are bound after the
+// where values, and therefore, they are numbered after them.
+func numberPlaceholders(q *DBQuery) {
+	q.dollar = true
+	q.CondExp, _ = numberExp(q.CondExp, 1)
+	n := 1
+	for i := range q.Clauses {
+		q.Clauses[i].Exp, n = numberExp(q.Clauses[i].Exp, n)
+	}
+	// the cursor value is bound after the where values (see DBQuery.cursorExp), and the
+	// having values are bound after it, and before the sort values.
+	n = len(q.CondVal) + q.cursorVals() + 1
+	q.HavingExp, _ = numberExp(q.HavingExp, n)
+	q.Sort, _ = numberExp(q.Sort, n+len(q.HavingVal))
+}
+
+// numberExp numbers the "?" placeholders of the expression, starting from n.
+// it returns the numbered expression and the next number.
+func numberExp(exp string, n int) (string, int) {
+	if !strings.Contains(exp, "?") {
+		return exp, n
+	}
+	var b strings.Builder
+	for i := 0; i < len(exp); i++ {
+		if exp[i] != '?' {
+			b.WriteByte(exp[i])
+			continue
+		}
+		b.WriteString("$" + strconv.Itoa(n))
+		n++
+	}
+	return b.String(), n
+}
+
+// ParseInto is like Parse, but it decodes the query into the given dest. It's useful for
+// callers that want to get the typed components of the query (e.g. Pagination or SortFields)
+// separately. dest is not modified if the parsing failed.
+func (b *Builder) ParseInto(params url.Values, dest *DBQuery) error {
+	q, err := b.Parse(params)
+	if err != nil {
+		return err
+	}
+	*dest = *q
+	return nil
+}
+
+// ParseJSON is like Parse, but the params are decoded from a JSON object. It's useful for
+// endpoints that accept the query in a request body, since it's too long for a query string.
+// the filters are keyed by their param names, and the values can be scalars or arrays of
+// scalars. keys that are not filters (e.g. the cursor param) fail the parsing. for example:
+//
+//	{"filters": {"name": "a8m", "age_in": ["1,2"]}, "sort": ["-age"], "limit": 10, "offset": 0, "search": ["foo"]}
+func (b *Builder) ParseJSON(r io.Reader) (*DBQuery, error) {
+	var body struct {
+		Filters map[string]interface{} `json:"filters"`
+		Sort    interface{}            `json:"sort"`
+		Limit   interface{}            `json:"limit"`
+		Offset  interface{}            `json:"offset"`
+		Search  interface{}            `json:"search"`
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, newParseError("", CodeInvalidValue, "invalid JSON body: %v", err)
+	}
+	params := make(url.Values)
+	// the filters object holds only filters. the control params (e.g. cursor or group) are
+	// not accepted in the body, since they could be passed as filters.
+	for name, v := range body.Filters {
+		if !b.isFilter(b.canonicalName(name)) {
+			return nil, newParseError(name, CodeUnknownField, "key '%s' is not a filter", name)
+		}
+		if err := addBodyValues(params, name, v); err != nil {
+			return nil, err
+		}
+	}
+	for name, v := range map[string]interface{}{b.SortParam: body.Sort, b.LimitParam: body.Limit, b.OffsetParam: body.Offset, b.SearchParam: body.Search} {
+		if err := addBodyValues(params, name, v); err != nil {
+			return nil, err
+		}
+	}
+	return b.Parse(params)
+}
+
+// isFilter reports whether the given param is a registered filter, a JSON field filter or a
+// multi-column filter.
+func (b *Builder) isFilter(name string) bool {
+	if _, ok := b.lookupFilter(name); ok {
+		return true
+	}
+	_, ok := b.multiColumnFields[name]
+	return ok
+}
+
+// addBodyValues adds the values of a JSON body field to the params. the value can be a
+// scalar, or an array of scalars. null values are ignored.
+func addBodyValues(params url.Values, name string, v interface{}) error {
+	values, ok := v.([]interface{})
+	if !ok {
+		values = []interface{}{v}
+	}
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			params.Add(name, v)
+		case json.Number:
+			params.Add(name, v.String())
+		case bool:
+			params.Add(name, strconv.FormatBool(v))
+		default:
+			return newParseError(name, CodeInvalidValue, "invalid JSON value for key '%s'", name)
+		}
+	}
+	return nil
+}
+
+// Scope is like Parse, but it returns the query as a gorm scope, that applies it on the
+// database instance. for example:
+//
+//	scope, err := b.Scope(r.URL.Query())
+//	if err != nil {
+//		return err
+//	}
+//	db.Scopes(scope, activeOnly).Find(&pets)
+func (b *Builder) Scope(params url.Values) (func(*gorm.DB) *gorm.DB, error) {
+	q, err := b.Parse(params)
+	if err != nil {
+		return nil, err
+	}
+	return q.Apply, nil
+}
+
 // ParseRequest is a helper function for parsing query from a request object
 func (b *Builder) ParseRequest(r *http.Request) (*DBQuery, error) {
 	return b.Parse(r.URL.Query())
 }
 
+// UnknownParams returns the sorted names of the params that don't map to any
+// filter, sort or control parameter of the builder. It's useful for debugging
+// clients, since Parse ignores these params silently.
+func (b *Builder) UnknownParams(params url.Values) []string {
+	if b.CaseInsensitiveParams {
+		params = b.canonicalParams(params)
+	}
+	var unknown []string
+	for name := range params {
+		if !b.knownParam(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// redactedValue replaces the values of the redacted params.
+const redactedValue = "REDACTED"
+
+// Redact returns a copy of the params in their canonical form, where the values of the
+// filters (and of any other param that may hold user data) are replaced by a placeholder.
+// the pagination and sort params are kept intact. It's useful for logging the shape of
+// a query without its values. e.g: "age_gt=REDACTED&limit=10".
+func (b *Builder) Redact(params url.Values) url.Values {
+	if b.CaseInsensitiveParams {
+		params = b.canonicalParams(params)
+	}
+	redacted := make(url.Values, len(params))
+	for name, values := range params {
+		switch name {
+		case b.LimitParam, b.OffsetParam, b.SortParam:
+			redacted[name] = append([]string(nil), values...)
+		default:
+			redacted[name] = make([]string, len(values))
+			for i := range values {
+				redacted[name][i] = redactedValue
+			}
+		}
+	}
+	return redacted
+}
+
+// FieldInfo describes a field that is registered in the builder. see Builder.Fields.
+type FieldInfo struct {
+	// Name is the name of the field in the query params. e.g: "age" or "owner.name".
+	Name string
+	// Column is the column of the field in the generated SQL. e.g: "age" or "owners.name".
+	Column string
+	// Operators are the filter operators of the field, sorted by name. the "eq" operator
+	// is available as the bare field name as well. empty if the field is not filterable.
+	Operators []string
+	Sortable  bool
+	// Selected indicates that the field is selected when ExplicitSelect is enabled.
+	Selected bool
+	// Detailed indicates that the field has the "detailed" option.
+	Detailed bool
+}
+
+// Fields returns the description of the registered fields, sorted by their names. It's
+// useful for generating documentation or a filter UI from the model. It's safe to call it
+// from multiple goroutines concurrently.
+func (b *Builder) Fields() []FieldInfo {
+	// the operators of the filters by their names. computed filters and having fields
+	// are not struct fields, and they are described by their filters only.
+	filters := make(map[string]*FieldInfo)
+	for _, f := range b.filterFields {
+		info, ok := filters[f.field]
+		if !ok {
+			info = &FieldInfo{Name: f.field, Column: f.column}
+			filters[f.field] = info
+		}
+		if f.op != "" {
+			info.Operators = append(info.Operators, f.op)
+		}
+	}
+	infos := make([]FieldInfo, 0, len(b.fields)+len(filters))
+	for _, info := range b.fields {
+		filter, ok := filters[info.Name]
+		if !ok && !info.Sortable && !info.Selected && !info.Detailed {
+			continue
+		}
+		if ok {
+			info.Operators = filter.Operators
+			delete(filters, info.Name)
+		}
+		infos = append(infos, info)
+	}
+	for _, info := range filters {
+		infos = append(infos, *info)
+	}
+	for i := range infos {
+		sort.Strings(infos[i].Operators)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ExampleQuery returns an example query with a param for each registered filter field, and
+// for each multi-column filter. the param uses a representative operator of the field type
+// (e.g. "like" for strings and "gte" for numbers), and a placeholder value of the field type.
+// fields without a valid placeholder (e.g. enums of non-string types) are skipped. It's useful
+// for generating API examples and smoke tests.
+func (b *Builder) ExampleQuery() url.Values {
+	var (
+		example = make(url.Values)
+		seen    = make(map[string]bool)
+	)
+	for _, name := range b.filterNames {
+		if filters, ok := b.multiColumnFields[name]; ok {
+			if v, ok := exampleValue(filters...); ok {
+				example.Set(name, v)
+			}
+			continue
+		}
+		f := b.filterFields[name]
+		if seen[f.field] {
+			continue
+		}
+		seen[f.field] = true
+		for _, op := range []string{exampleOp(f.typ), "", opEqual} {
+			param := f.field
+			if op != "" {
+				param += b.Separator + op
+			}
+			filter, ok := b.filterFields[param]
+			if !ok {
+				continue
+			}
+			if v, ok := exampleValue(filter); ok {
+				example.Set(param, v)
+				break
+			}
+		}
+	}
+	return example
+}
+
+// exampleOp returns a representative operator of the given field type.
+func exampleOp(typ reflect.Type) string {
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == nil:
+		return ""
+	case isTime(typ):
+		return opGreaterThanOrEqual
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return opLike
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return opGreaterThanOrEqual
+	default:
+		return ""
+	}
+}
+
+// exampleValue returns a placeholder value of the type of the first given filter, if it's
+// valid for all of them.
+func exampleValue(filters ...filterField) (string, bool) {
+	typ := filters[0].typ
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	v := "1"
+	switch {
+	case typ == nil:
+	case isTime(typ):
+		v = "2020-01-01T00:00:00Z"
+	case typ == reflect.TypeOf(time.Duration(0)):
+		v = "1h"
+	case typ.Kind() == reflect.Bool:
+		v = "true"
+	case typ.Kind() == reflect.String:
+		v = "example"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		v = "1.5"
+	}
+	for _, f := range filters {
+		if f.parse == nil {
+			return "", false
+		}
+		if _, ok := f.parse(v); !ok {
+			return "", false
+		}
+	}
+	return v, true
+}
+
+// knownParam reports whether the given param name is recognized by the builder.
+func (b *Builder) knownParam(name string) bool {
+	for _, param := range b.controlParams() {
+		if param == name {
+			return true
+		}
+	}
+	_, ok := b.lookupFilter(name)
+	return ok
+}
+
+// ReservedParams returns the sorted names of the params that are reserved by the builder,
+// given its config. i.e: the limit, offset and sort params, the params of the enabled
+// features (search, cursor, or, group and distinct), the opt-out params of the base
+// conditions and the Config.ReservedParams. filter fields can not use these names.
+func (b *Builder) ReservedParams() []string {
+	params := []string{b.LimitParam, b.OffsetParam, b.SortParam}
+	if b.searchable() {
+		params = append(params, b.SearchParam)
+	}
+	if b.CursorField != "" {
+		params = append(params, b.CursorParam, b.BeforeParam)
+	}
+	if b.OrParam != "" {
+		params = append(params, b.OrParam)
+	}
+	if len(b.groupFields) > 0 {
+		params = append(params, b.GroupParam)
+	}
+	if b.AllowDistinct {
+		params = append(params, distinctParam)
+	}
+	params = append(params, b.Config.ReservedParams...)
+	for _, c := range b.BaseConditions {
+		if c.OptOutParam != "" {
+			params = append(params, c.OptOutParam)
+		}
+	}
+	sort.Strings(params)
+	reserved := params[:0]
+	for i, param := range params {
+		if i == 0 || param != params[i-1] {
+			reserved = append(reserved, param)
+		}
+	}
+	return reserved
+}
+
+// controlParams returns the names of the params that are not registered as filter
+// fields, but are recognized by the builder.
+// the search param is recognized even if the model is not searchable.
+func (b *Builder) controlParams() []string {
+	params := append(b.ReservedParams(), b.SearchParam)
+	for name := range b.MultiColumnFilters {
+		params = append(params, name)
+	}
+	return params
+}
+
+// canonicalParams returns a copy of the params, where the names that match a registered
+// param case-insensitively are replaced with the registered name. unknown names are kept.
+func (b *Builder) canonicalParams(params url.Values) url.Values {
+	canonical := make(url.Values, len(params))
+	for name, values := range params {
+		name = b.canonicalName(name)
+		canonical[name] = append(canonical[name], values...)
+	}
+	return canonical
+}
+
+// canonicalName returns the registered name of the given param. see Config.CaseInsensitiveParams.
+func (b *Builder) canonicalName(name string) string {
+	if registered, ok := b.paramNames[strings.ToLower(name)]; ok {
+		return registered
+	}
+	return name
+}
+
+// searchable reports whether the model implements one of the search interfaces.
+func (b *Builder) searchable() bool {
+	return b.searcher != nil || b.multiSearch != nil
+}
+
 // parseSearch generates search query for the given terms.
 func (b *Builder) parseSearch(terms []string) (string, []interface{}) {
+	if b.multiSearch != nil {
+		return b.multiSearch.SearchAll(terms)
+	}
 	var (
 		vals []interface{}
 		exp  = new(bytes.Buffer)
@@ -193,71 +1611,519 @@ func (b *Builder) parseSearch(terms []string) (string, []interface{}) {
 	return exp.String(), vals
 }
 
-// parseFilter builds condition expression and condition values from
-// the given params based on the struct configuration.
-func (b *Builder) parseFilter(params url.Values) (string, []interface{}, error) {
+// parseFilter builds the condition clauses from the given params based
+// on the struct configuration. a clause is created for each filter.
+func (b *Builder) parseFilter(ctx context.Context, params url.Values, rewrite func(string) string) ([]Clause, []string, error) {
 	var (
-		filterExp []string
-		filterVal []interface{}
+		clauses []Clause
+		joins   []string
 	)
-	for name, filter := range b.filterFields {
+	// the params are visited in a sorted order, so the clauses and their values are
+	// stable across calls, and CondVal follows the placeholders order of CondExp.
+	for _, name := range b.filterNames {
 		args, ok := params[name]
 		// ignore irrelevant fields
 		if !ok {
 			continue
 		}
-		if filter.splitOnComma && len(args) == 1 && strings.Contains(args[0], ",") {
-			args = strings.Split(args[0], ",")
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
 		}
-		// there are two expression formats:
-		// 1. "KEY = VAL"                     - when only one argument is given.
-		// 2. "(KEY = VAL OR KEY = VAL2 ...)" - when multiple values are given.
-		// we use "=" in this example, but it could be any other operator.
-		expArgs := make([]string, 0, len(args))
-		for _, arg := range args {
-			v, ok := filter.parse(arg)
-			if !ok {
-				return "", nil, &ParseError{fmt.Sprintf("invalid parameter for key '%s'", name)}
+		if b.DoubleDecodeValues {
+			args = unescapeAll(args)
+		}
+		var (
+			clause Clause
+			err    error
+		)
+		if filters, ok := b.multiColumnFields[name]; ok {
+			clause, err = b.multiColumnClause(name, args, filters, rewrite)
+			if err != nil {
+				return nil, nil, err
+			}
+			clauses = append(clauses, clause)
+			for _, filter := range filters {
+				if joins, err = b.addJoins(name, joins, filter.joins); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		filter, ok := b.filterFields[name]
+		if !ok || filter.having {
+			continue
+		}
+		if clause, err = b.filterClause(name, filter, args, rewrite); err != nil {
+			return nil, nil, err
+		}
+		clauses = append(clauses, clause)
+		if joins, err = b.addJoins(name, joins, filter.joins); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, name := range b.jsonParams(params) {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		args := params[name]
+		if b.DoubleDecodeValues {
+			args = unescapeAll(args)
+		}
+		filter, _ := b.jsonFilter(name)
+		clause, err := b.filterClause(name, filter, args, rewrite)
+		if err != nil {
+			return nil, nil, err
+		}
+		clauses = append(clauses, clause)
+		if joins, err = b.addJoins(name, joins, filter.joins); err != nil {
+			return nil, nil, err
+		}
+	}
+	// the OR group is combined with the other filters with AND.
+	if terms, ok := params[b.OrParam]; ok && b.OrParam != "" {
+		if b.DoubleDecodeValues {
+			terms = unescapeAll(terms)
+		}
+		clause, groupJoins, err := b.orGroup(terms, joins, rewrite)
+		if err != nil {
+			return nil, nil, err
+		}
+		clauses, joins = append(clauses, clause), groupJoins
+	}
+	return clauses, joins, nil
+}
+
+// jsonField is a JSON column, that is filtered by its keys. see jsonFilter.
+type jsonField struct {
+	// column of the field. e.g: "meta".
+	column string
+	// filters are the string filters of the field by their operators, with an empty column.
+	filters map[string]filterField
+}
+
+// jsonKey matches the keys of JSON fields that can be used in the filter params. the key
+// is added to the expression as a literal, and therefore, it's restricted.
+var jsonKey = regexp.MustCompile(`^[\w-]+$`)
+
+// lookupFilter returns the filter of the given param name, including JSON field filters.
+func (b *Builder) lookupFilter(name string) (filterField, bool) {
+	if f, ok := b.filterFields[name]; ok {
+		return f, true
+	}
+	return b.jsonFilter(name)
+}
+
+// jsonFilter returns the filter of the given JSON field param. the param is the field name,
+// the key and an optional operator. e.g: "meta.color_eq" is "meta->>'color' = ?".
+func (b *Builder) jsonFilter(name string) (filterField, bool) {
+	i := strings.Index(name, b.JoinSeparator)
+	if i <= 0 || len(b.jsonFields) == 0 {
+		return filterField{}, false
+	}
+	jf, ok := b.jsonFields[name[:i]]
+	if !ok {
+		return filterField{}, false
+	}
+	// the longest operator suffix wins. e.g: "not_in" and not "in".
+	key, op := name[i+len(b.JoinSeparator):], ""
+	for o := range jf.filters {
+		suffix := b.Separator + o
+		if o != "" && len(o) > len(op) && len(key) > len(suffix) && strings.HasSuffix(key, suffix) {
+			op = o
+		}
+	}
+	if op != "" {
+		key = strings.TrimSuffix(key, b.Separator+op)
+	}
+	if !jsonKey.MatchString(key) {
+		return filterField{}, false
+	}
+	f := jf.filters[op]
+	f.field, f.column = name[:i], fmt.Sprintf("%s->>'%s'", jf.column, key)
+	return f, true
+}
+
+// jsonParams returns the names of the JSON field params, in a sorted order.
+func (b *Builder) jsonParams(params url.Values) []string {
+	if len(b.jsonFields) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range params {
+		if _, ok := b.filterFields[name]; ok {
+			continue
+		}
+		if _, ok := b.jsonFilter(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addJSONField registers the string filters of a JSON field. the filters are registered in
+// a separate map, since their columns are built from the param names. JSON fields are
+// supported only on Postgres.
+func (b *Builder) addJSONField(f fieldOptions) {
+	if b.Dialect != DialectPostgres {
+		return
+	}
+	fields := b.filterFields
+	b.filterFields = make(map[string]filterField)
+	b.addStringField(fieldOptions{computed: true, typ: reflect.TypeOf(""), wrap: nopWrapper, joins: f.joins})
+	filters := make(map[string]filterField, len(b.filterFields))
+	for _, filter := range b.filterFields {
+		filters[filter.op] = filter
+	}
+	b.filterFields = fields
+	b.jsonFields[f.name] = jsonField{column: f.column, filters: filters}
+}
+
+// parseHaving builds the clauses of the having filters. see Config.HavingFields.
+func (b *Builder) parseHaving(params url.Values, rewrite func(string) string) ([]Clause, error) {
+	var clauses []Clause
+	for _, name := range b.filterNames {
+		filter, ok := b.filterFields[name]
+		if !ok || !filter.having {
+			continue
+		}
+		args, ok := params[name]
+		if !ok {
+			continue
+		}
+		if b.DoubleDecodeValues {
+			args = unescapeAll(args)
+		}
+		clause, err := b.filterClause(name, filter, args, rewrite)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses, nil
+}
+
+// filterClause validates the arguments of the given filter param, and builds its clause.
+func (b *Builder) filterClause(name string, filter filterField, args []string, rewrite func(string) string) (Clause, error) {
+	if err := filter.checkArity(name, args); err != nil {
+		return Clause{}, err
+	}
+	// two values of a bare time field are a range. e.g: "created_at=t1&created_at=t2".
+	// filter is a copy of the registered field, and therefore, it's safe to modify it.
+	if b.BareRangeForTime && filter.op == "" && len(args) == 2 && isTime(filter.typ) {
+		filter.op, filter.format = opBetween, "%s BETWEEN ? AND ?"
+		args = []string{args[0] + "," + args[1]}
+	}
+	var (
+		clause Clause
+		err    error
+	)
+	switch filter.op {
+	case opIn, opNotIn:
+		clause, err = filter.listClause(name, args, b.Placeholder == PlaceholderDollar, rewrite)
+	case opNull:
+		clause, err = filter.nullClause(name, args, b.parseBool, rewrite)
+	case opBetween:
+		clause, err = filter.betweenClause(name, args, rewrite)
+	default:
+		clause, err = filter.clause(name, args, rewrite)
+	}
+	if err == nil && filter.wrapValues != nil {
+		clause.Exp, clause.Vals = filter.wrapValues(clause.Exp, clause.Vals)
+	}
+	return clause, err
+}
+
+// orGroup builds the clause of the OR group from its terms. each term is a filter param and
+// its value, separated by a colon (e.g. "name_like:a8m"), and it's parsed like the filter param.
+// it returns the clause, and the given joins with the joins of the terms.
+func (b *Builder) orGroup(terms []string, joins []string, rewrite func(string) string) (Clause, []string, error) {
+	if b.MaxOrGroups > 0 && len(terms) > b.MaxOrGroups {
+		return Clause{}, nil, newParseError(b.OrParam, CodeNotAllowed, "too many terms in key '%s' (max %d)", b.OrParam, b.MaxOrGroups)
+	}
+	var (
+		exps []string
+		vals []interface{}
+	)
+	for _, term := range terms {
+		i := strings.Index(term, ":")
+		if i <= 0 {
+			return Clause{}, nil, newParseError(b.OrParam, CodeInvalidValue, "invalid value('%s') for key '%s'", term, b.OrParam)
+		}
+		var (
+			name, args = term[:i], []string{term[i+1:]}
+			clause     Clause
+			fields     []filterField
+			err        error
+		)
+		if filters, ok := b.multiColumnFields[name]; ok {
+			clause, err = b.multiColumnClause(name, args, filters, rewrite)
+			fields = filters
+		} else if filter, ok := b.lookupFilter(name); ok && !filter.having {
+			clause, err = b.filterClause(name, filter, args, rewrite)
+			fields = []filterField{filter}
+		} else {
+			return Clause{}, nil, newParseError(b.OrParam, CodeUnknownField, "unknown filter '%s' in key '%s'", name, b.OrParam)
+		}
+		if err != nil {
+			return Clause{}, nil, err
+		}
+		for _, f := range fields {
+			if joins, err = b.addJoins(name, joins, f.joins); err != nil {
+				return Clause{}, nil, err
+			}
+		}
+		exps = append(exps, clause.Exp)
+		vals = append(vals, clause.Vals...)
+	}
+	return Clause{Exp: "(" + strings.Join(exps, " OR ") + ")", Vals: vals}, joins, nil
+}
+
+// addJoins adds the joins of the given filter to the joins of the query, and
+// validates them against the MaxJoinDepth and MaxJoins limits.
+func (b *Builder) addJoins(name string, joins []string, add []string) ([]string, error) {
+	if b.MaxJoinDepth > 0 && len(add) > b.MaxJoinDepth {
+		return nil, newParseError(name, CodeNotAllowed, "filter '%s' exceeds the maximum join depth (%d)", name, b.MaxJoinDepth)
+	}
+	joins = appendJoins(joins, add)
+	if b.MaxJoins > 0 && len(joins) > b.MaxJoins {
+		return nil, newParseError(name, CodeNotAllowed, "too many joins in query (max %d)", b.MaxJoins)
+	}
+	return joins, nil
+}
+
+// appendJoins appends the given joins to the list, skipping the ones that already exist.
+func appendJoins(joins []string, add []string) []string {
+Add:
+	for _, j := range add {
+		for i := range joins {
+			if joins[i] == j {
+				continue Add
+			}
+		}
+		joins = append(joins, j)
+	}
+	return joins
+}
+
+// unescapeAll returns a copy of the given values after unescaping them. values that
+// can not be unescaped (e.g. "100%") are kept as is.
+func unescapeAll(values []string) []string {
+	unescaped := make([]string, len(values))
+	for i, v := range values {
+		if u, err := url.QueryUnescape(v); err == nil {
+			v = u
+		}
+		unescaped[i] = v
+	}
+	return unescaped
+}
+
+// multiColumnClause expands a multi-column filter to all its columns, combined with "OR".
+// for example: "(updated_at >= ? OR created_at >= ?)". the clause of each column is built
+// like the clause of its filter, and therefore, it's wrapped by its ValueWrapper.
+func (b *Builder) multiColumnClause(name string, args []string, filters []filterField, rewrite func(string) string) (Clause, error) {
+	var (
+		expArgs = make([]string, 0, len(args))
+		vals    = make([]interface{}, 0, len(args)*len(filters))
+	)
+	for _, arg := range args {
+		exps := make([]string, 0, len(filters))
+		for _, filter := range filters {
+			clause, err := b.filterClause(name, filter, []string{arg}, rewrite)
+			if err != nil {
+				return Clause{}, err
 			}
-			filterVal = append(filterVal, v)
-			// collect expressions.
-			expArgs = append(expArgs, filter.exp)
-		}
-		// if there's more than one argument, concatenate with "OR".
-		exp := strings.Join(expArgs, " OR ")
-		if len(expArgs) > 1 {
-			exp = "(" + exp + ")"
+			vals = append(vals, clause.Vals...)
+			exps = append(exps, clause.Exp)
 		}
-		filterExp = append(filterExp, filter.wrap(exp))
+		expArgs = append(expArgs, "("+strings.Join(exps, " OR ")+")")
 	}
-	return strings.Join(filterExp, " AND "), filterVal, nil
+	exp := strings.Join(expArgs, " OR ")
+	if len(expArgs) > 1 {
+		exp = "(" + exp + ")"
+	}
+	return Clause{Exp: exp, Vals: vals}, nil
 }
 
-// parseSort builds a sort input for the DBQuery.
+// parseSort builds the sort input of the DBQuery.
 // sort param could be string with prefixed by '-', or '+' and
 // an ordering indicator.
-func (b *Builder) parseSort(fields []string) (string, error) {
-	sortParams := make([]string, len(fields))
+func (b *Builder) parseSort(ctx context.Context, q *DBQuery, fields []string, rewrite func(string) string) error {
+	if b.MaxSortFields > 0 && len(fields) > b.MaxSortFields {
+		return newParseError(b.SortParam, CodeNotAllowed, "too many sort fields in query (max %d)", b.MaxSortFields)
+	}
+	var (
+		sortParams = make([]string, len(fields))
+		sortFields = make([]SortField, len(fields))
+		sortVals   []interface{}
+		seen       = make(map[string]bool, len(fields))
+	)
 	for i, field := range fields {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if field == "" {
-			return "", &ParseError{"missing sort parameter"}
+			return newParseError(b.SortParam, CodeInvalidValue, "missing sort parameter")
 		}
 		var orderBy string
 		// if the sort field prefixed by order indicator
 		if order, ok := sortDirections[field[0]]; ok {
 			orderBy = order
 			field = field[1:]
+		} else if b.DefaultOrderDirection == "desc" || b.sortDesc[field] {
+			orderBy = "desc"
 		}
-		if !b.sortFields[field] {
-			return "", &ParseError{fmt.Sprintf("invalid sort parameter '%s'", field)}
+		if seen[field] && !b.AllowDuplicateSort {
+			return newParseError(b.SortParam, CodeInvalidValue, "duplicate sort parameter '%s'", field)
+		}
+		seen[field] = true
+		sortFields[i] = SortField{Column: field, Desc: orderBy == "desc"}
+		nulls := b.SortNulls[field]
+		switch computed, ok := b.computedSort(field); {
+		case ok:
+			field = computed.Exp
+			sortVals = append(sortVals, computed.Vals...)
+		case b.sortFields[field]:
+			field = rewrite(b.column(field))
+			sortFields[i].Column = field
+		default:
+			return newParseError(b.SortParam, CodeUnknownField, "invalid sort parameter '%s'", field)
 		}
-		sortParams[i] = field
 		if orderBy != "" {
 			field += " " + orderBy
 		}
+		// MySQL doesn't support the NULLS clause.
+		if nulls != "" && b.Dialect != DialectMySQL {
+			field += " NULLS " + strings.ToUpper(nulls)
+		}
 		sortParams[i] = field
 	}
-	return strings.Join(sortParams, ", "), nil
+	q.Sort, q.SortVal, q.SortFields = strings.Join(sortParams, ", "), sortVals, sortFields
+	return nil
+}
+
+// column returns the column of the given model field name. see Builder.columns.
+func (b *Builder) column(name string) string {
+	if column, ok := b.columns[name]; ok {
+		return column
+	}
+	return name
+}
+
+// computedSort returns the server-defined sort expression of the given sort key, from
+// the ComputedSorts or the SortExpressions of the config.
+func (b *Builder) computedSort(name string) (ComputedSort, bool) {
+	if computed, ok := b.ComputedSorts[name]; ok {
+		return computed, true
+	}
+	if exp, ok := b.SortExpressions[name]; ok {
+		return ComputedSort{Exp: "(" + exp + ")"}, true
+	}
+	return ComputedSort{}, false
+}
+
+// stableSort appends the StableSortKey to the sort of the query, if it's not already there.
+func (b *Builder) stableSort(q *DBQuery, rewrite func(string) string) {
+	column := rewrite(b.StableSortKey)
+	for _, f := range q.SortFields {
+		if f.Column == column {
+			return
+		}
+	}
+	q.Sort += ", " + column
+	q.SortFields = append(q.SortFields, SortField{Column: column})
+}
+
+// parseGroup sets the GROUP BY columns of the query, from the group params or from the
+// default GroupBy. the select of a grouped query is its group columns, since the other
+// columns can not be selected. aggregates can be added to it after the parsing.
+func (b *Builder) parseGroup(q *DBQuery, fields []string, rewrite func(string) string) error {
+	if len(fields) == 0 || len(b.groupFields) == 0 {
+		fields = nil
+		for _, field := range strings.Split(b.GroupBy, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	} else {
+		columns := make([]string, len(fields))
+		for i, field := range fields {
+			if !b.groupFields[field] {
+				return newParseError(b.GroupParam, CodeUnknownField, "invalid group parameter '%s'", field)
+			}
+			columns[i] = b.column(field)
+		}
+		fields = columns
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	q.GroupBy = strings.Join(rewriteAll(fields, rewrite), ", ")
+	q.Select = q.GroupBy
+	return nil
+}
+
+// defaultSelect returns the given select expression, or "*" if it's empty.
+func defaultSelect(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+// parseSortFields splits the given sort expression into its fields. for example: "name desc, age".
+func parseSortFields(sort string) []SortField {
+	var fields []SortField
+	for _, part := range strings.Split(sort, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			continue
+		}
+		field := SortField{Column: words[0]}
+		if len(words) > 1 {
+			field.Desc = strings.EqualFold(words[1], "desc")
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// rewriteSort rewrites the columns of the given sort expression. for example: "name desc, age".
+func rewriteSort(sort string, rewrite func(string) string) string {
+	if sort == "" {
+		return sort
+	}
+	parts := strings.Split(sort, ",")
+	for i, part := range parts {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = rewrite(fields[0])
+		parts[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// rewriteAll returns a copy of the given columns after rewriting them.
+func rewriteAll(columns []string, rewrite func(string) string) []string {
+	rewritten := make([]string, len(columns))
+	for i := range columns {
+		rewritten[i] = rewrite(columns[i])
+	}
+	return rewritten
+}
+
+// parseOptOut parses the value of an opt-out param. an empty value means no opt-out.
+func parseOptOut(k, v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	optOut, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, newParseError(k, CodeInvalidValue, "invalid value('%s') for key '%s'", v, k)
+	}
+	return optOut, nil
 }
 
 // parse number. return an error if the string is invalid
@@ -265,31 +2131,70 @@ func (b *Builder) parseSort(fields []string) (string, error) {
 func parseNumber(k, v string, min, max int) (int, error) {
 	n, err := strconv.Atoi(v)
 	if err != nil {
-		return 0, &ParseError{fmt.Sprintf("invalid value('%s') for key '%s'", v, k)}
+		return 0, newParseError(k, CodeInvalidValue, "invalid value('%s') for key '%s'", v, k)
 	}
 	if n < min {
-		return 0, &ParseError{fmt.Sprintf("value for key '%s' must be greater than or equal to %d", k, min)}
+		return 0, newParseError(k, CodeOutOfRange, "value for key '%s' must be greater than or equal to %d", k, min)
 	}
 	if max != -1 && n > max {
-		return 0, &ParseError{fmt.Sprintf("value for key '%s' must be less than or equal to %d", k, max)}
+		return 0, newParseError(k, CodeOutOfRange, "value for key '%s' must be less than or equal to %d", k, max)
 	}
 	return n, nil
 }
 
-func (b *Builder) addFilterFieldsForNumericFields(withSep, colName string, parse parseFn, splitOnComma bool) {
-	b.addFilterField(colName, colName+" = ?", parse, splitOnComma)
-	b.addFilterField(withSep+opEqual, colName+" = ?", parse, splitOnComma)
-	b.addFilterField(withSep+opNotEqual, colName+" <> ?", parse, splitOnComma)
-	b.addFilterField(withSep+opLessThan, colName+" < ?", parse, splitOnComma)
-	b.addFilterField(withSep+opLessThanOrEqual, colName+" <= ?", parse, splitOnComma)
-	b.addFilterField(withSep+opGreaterThan, colName+" > ?", parse, splitOnComma)
-	b.addFilterField(withSep+opGreaterThanOrEqual, colName+" >= ?", parse, splitOnComma)
+func (b *Builder) addFilterFieldsForNumericFields(f fieldOptions, parse parseFn) {
+	b.addFilterField(f, "", "%s = ?", parse)
+	b.addFilterField(f, opEqual, "%s = ?", parse)
+	b.addFilterField(f, opNotEqual, b.notEqualFormat(f), parse)
+	b.addFilterField(f, opLessThan, "%s < ?", parse)
+	b.addFilterField(f, opLessThanOrEqual, "%s <= ?", parse)
+	b.addFilterField(f, opGreaterThan, "%s > ?", parse)
+	b.addFilterField(f, opGreaterThanOrEqual, "%s >= ?", parse)
+	b.addFilterField(f, opIn, "%s IN (?)", parse)
+	b.addFilterField(f, opNotIn, "%s NOT IN (?)", parse)
+	b.addFilterField(f, opBetween, "%s BETWEEN ? AND ?", parse)
+}
+
+func (b *Builder) addFilterFieldsForBoolFields(f fieldOptions, parse parseFn) {
+	b.addFilterField(f, "", "%s = ?", parse)
+	b.addFilterField(f, opEqual, "%s = ?", parse)
+	b.addFilterField(f, opNotEqual, b.notEqualFormat(f), parse)
+}
+
+// addFilterFieldsForTextFields adds the equality filters to fields that implement the
+// encoding.TextUnmarshaler interface. i.e: uuid.UUID.
+func (b *Builder) addFilterFieldsForTextFields(f fieldOptions, parse parseFn) {
+	b.addFilterField(f, "", "%s = ?", parse)
+	b.addFilterField(f, opEqual, "%s = ?", parse)
+	b.addFilterField(f, opNotEqual, b.notEqualFormat(f), parse)
+	b.addFilterField(f, opIn, "%s IN (?)", parse)
+	b.addFilterField(f, opNotIn, "%s NOT IN (?)", parse)
+}
+
+// notEqualFormat returns the expression format for the "neq" operator. if NullSafeNeq
+// is enabled and the column is nullable, rows with NULL value are matched as well.
+func (b *Builder) notEqualFormat(f fieldOptions) string {
+	if b.NullSafeNeq && f.nullable {
+		return "(%[1]s <> ? OR %[1]s IS NULL)"
+	}
+	return "%s <> ?"
+}
+
+// iNotEqualFormat returns the expression format for the case-insensitive "ineq" operator.
+func (b *Builder) iNotEqualFormat(f fieldOptions) string {
+	if b.NullSafeNeq && f.nullable {
+		return "(LOWER(%[1]s) <> LOWER(?) OR %[1]s IS NULL)"
+	}
+	return "LOWER(%s) <> LOWER(?)"
 }
 
-func (b *Builder) addFilterFieldsForBoolFields(withSep, colName string, parse parseFn, splitOnComma bool) {
-	b.addFilterField(colName, colName+" = ?", parse, splitOnComma)
-	b.addFilterField(withSep+opEqual, colName+" = ?", parse, splitOnComma)
-	b.addFilterField(withSep+opNotEqual, colName+" <> ?", parse, splitOnComma)
+// iLikeFormat returns the expression format for the case-insensitive "ilike" operator.
+// Postgres has a native ILIKE operator, other dialects compare the lower-cased values.
+func (b *Builder) iLikeFormat() string {
+	if b.Dialect == DialectPostgres {
+		return "%s ILIKE ?"
+	}
+	return "LOWER(%s) LIKE LOWER(?)"
 }
 
 var (
@@ -301,12 +2206,17 @@ var (
 	}
 )
 
+// appendToSelect adds the column to the explicit select. fields with the "noselect" option
+// are never selected, even if they have the "detailed" option too. they can still be filtered.
 func (b *Builder) appendToSelect(colName string, gormOptions []string, options []string) {
 	for _, s := range ignoreOptions {
 		if contains(gormOptions, s) {
 			return
 		}
 	}
+	if contains(options, noSelectTag) {
+		return
+	}
 	if b.OnlySelectNonDetailedFields && contains(options, detailedTag) {
 		return
 	}
@@ -315,59 +2225,181 @@ func (b *Builder) appendToSelect(colName string, gormOptions []string, options [
 
 }
 
-// parseField handle sort and filter fields.
-func (b *Builder) parseField(field *structs.Field) {
+// parseField handle sort and filter fields. rel is the relation of a joined model
+// field, and it's zero for the fields of the model itself.
+func (b *Builder) parseField(field *structs.Field, rel relation) {
 	colName := gorm.ToDBName(field.Name())
 
 	// get all options from the struct field.
 	options := strings.Split(field.Tag(b.TagName), ",")
 	gormOptions := strings.Split(field.Tag("gorm"), ";")
 
-	if b.ExplicitSelect {
-		b.appendToSelect(colName, gormOptions, options)
+	// the physical column of the field, if it doesn't follow the gorm naming convention.
+	// the column option takes precedence over the column of the gorm tag.
+	column, hasColumn := tagOption(options, columnTag)
+	if !hasColumn {
+		column, hasColumn = gormColumn(gormOptions)
+	}
+	if !hasColumn {
+		column = colName
+	}
+	// the field parsers are keyed by the column, that is qualified for joined models.
+	parserKey := column
+	if rel.table != "" {
+		parserKey = rel.table + "." + column
+	}
+
+	// non-embedded struct fields that are tagged for filtering are joined models.
+	if contains(options, filterTag) && b.FieldParsers[parserKey] == nil {
+		if typ, ok := relationType(field.Value()); ok {
+			b.parseRelation(field, typ, gormOptions, rel)
+			return
+		}
+	}
+
+	// select and sort are supported only for the fields of the model.
+	if rel.table == "" && !contains(gormOptions, "-") {
+		b.columns[colName] = column
+	}
+	selected := len(b.selectFields)
+	if b.ExplicitSelect && rel.table == "" {
+		b.appendToSelect(column, gormOptions, options)
 	}
 
 	// struct field has a sort option.
-	if contains(options, sortTag) {
+	if contains(options, sortTag) && rel.table == "" {
+		b.sortFields[colName] = true
+	}
+	// struct field is sorted in descending order, if the sort param has no order indicator.
+	if contains(options, sortDescTag) && rel.table == "" {
 		b.sortFields[colName] = true
+		b.sortDesc[colName] = true
+	}
+	// struct field has a group option.
+	if contains(options, groupTag) && rel.table == "" {
+		b.groupFields[colName] = true
+	}
+	info := FieldInfo{
+		Name:     rel.prefix + colName,
+		Column:   column,
+		Sortable: b.sortFields[colName] && rel.table == "",
+		Selected: len(b.selectFields) > selected,
+		Detailed: contains(options, detailedTag) && rel.table == "",
+	}
+	if rel.table != "" {
+		info.Column = rel.table + "." + column
 	}
+	if param, ok := hasQueryParam(options); ok && contains(options, filterTag) {
+		info.Name = rel.prefix + param
+	}
+	b.fields = append(b.fields, info)
 	// struct field has a filter option.
 	if !contains(options, filterTag) {
 		return
 	}
 
-	splitOnComma := contains(options, splitTag)
-	// if it has custom query-param, use it instead.
+	// if it has custom query-param, use it instead. the param is also the column name,
+	// unless the column is set explicitly with the column option.
 	if field, ok := hasQueryParam(options); ok {
 		colName = field
+		if !hasColumn {
+			column = field
+		}
 	}
-	var (
-		v       = field.Value()
-		wrapFn  = nopWrapper
-		withSep = colName + b.Separator
-	)
-	// custom type may implements the Wrapper interface.
-	if wrapper, ok := v.(Wrapper); ok {
-		wrapFn = wrapper.Wrap
+	v := field.Value()
+	multi, _ := tagOption(options, multiTag)
+	if multi != "" && multi != "and" && multi != "or" {
+		panic(fmt.Sprintf("Invalid multi option '%s' of field %s", multi, field.Name()))
+	}
+	f := fieldOptions{
+		name:         rel.prefix + colName,
+		column:       column,
+		typ:          reflect.TypeOf(v),
+		wrap:         nopWrapper,
+		splitOnComma: contains(options, splitTag) || b.SplitOnComma && !contains(options, noSplitTag),
+		nullable:     field.Kind() == reflect.Ptr,
+		multiAnd:     multi == "and",
+	}
+	// fields of joined models are qualified with their table name.
+	if rel.table != "" {
+		f.column = rel.table + "." + column
+		f.computed, f.joins = true, rel.joins
+	} else {
+		f.structField = field.Name()
+	}
+	// custom type may implements the ValueWrapper, or the Wrapper interface.
+	if wrapper, ok := valueWrapperOf(v); ok {
+		f.wrapValues = wrapper.WrapValues
+	} else if wrapper, ok := wrapperOf(v); ok {
+		f.wrap = wrapper.Wrap
+	}
+	if parse, ok := b.FieldParsers[parserKey]; ok {
+		f.parse = parse
+		b.usedParsers[parserKey] = true
+	}
+	if enum, ok := enumerator(f.typ); ok {
+		f.enum = enumValues(enum)
+	}
+	// JSON fields are filtered by their keys. e.g: "meta.color_eq=red".
+	if contains(options, jsonTag) {
+		typ := reflect.TypeOf(v)
+		if typ.Kind() != reflect.Map && typ != reflect.TypeOf(json.RawMessage{}) {
+			panic(fmt.Sprintf("Could not use field %s (%T) as a json filter", field.Name(), v))
+		}
+		b.addJSONField(f)
+		return
 	}
+	// the null operator is supported by all types. the expression is built by nullClause.
+	b.addFilterField(f, opNull, "", nil)
 	switch v.(type) {
 	case string, *string:
-		b.addStringField(colName, withSep, splitOnComma, wrapFn)
+		b.addStringField(f)
 	case int, *int:
 		parseFn := parseInt
-		b.addFilterFieldsForNumericFields(withSep, colName, parseFn, splitOnComma)
+		b.addFilterFieldsForNumericFields(f, parseFn)
 	case int64, *int64:
 		parseFn := parseInt64
-		b.addFilterFieldsForNumericFields(withSep, colName, parseFn, splitOnComma)
+		b.addFilterFieldsForNumericFields(f, parseFn)
+	case uint, *uint:
+		parseFn := parseUint
+		b.addFilterFieldsForNumericFields(f, parseFn)
+	case uint64, *uint64:
+		parseFn := parseUint64
+		b.addFilterFieldsForNumericFields(f, parseFn)
+	case float64, *float64, float32, *float32:
+		parseFn := parseFloat
+		b.addFilterFieldsForNumericFields(f, parseFn)
+	case time.Duration, *time.Duration:
+		f.bind = (*Builder).parseDuration
+		b.addFilterFieldsForNumericFields(f, f.bind.on(b))
 	case time.Time:
-		parseFn := parseDate
-		b.addFilterFieldsForNumericFields(withSep, colName, parseFn, splitOnComma)
+		f.bind = (*Builder).parseDate
+		b.addFilterFieldsForNumericFields(f, f.bind.on(b))
 	case *time.Time:
-		parseFn := parseDatePointer
-		b.addFilterFieldsForNumericFields(withSep, colName, parseFn, splitOnComma)
+		f.bind = (*Builder).parseDatePointer
+		b.addFilterFieldsForNumericFields(f, f.bind.on(b))
 	case bool, *bool:
-		parseFn := parseBool
-		b.addFilterFieldsForBoolFields(withSep, colName, parseFn, splitOnComma)
+		f.bind = (*Builder).parseBool
+		b.addFilterFieldsForBoolFields(f, f.bind.on(b))
+	// the sql.Null* types are parsed like their inner types, and they are nullable.
+	case sql.NullString, *sql.NullString:
+		f.nullable = true
+		b.addStringField(f)
+	case sql.NullInt64, *sql.NullInt64:
+		f.nullable = true
+		b.addFilterFieldsForNumericFields(f, parseInt64)
+	case sql.NullInt32, *sql.NullInt32:
+		f.nullable = true
+		b.addFilterFieldsForNumericFields(f, parseInt32)
+	case sql.NullFloat64, *sql.NullFloat64:
+		f.nullable = true
+		b.addFilterFieldsForNumericFields(f, parseFloat)
+	case sql.NullBool, *sql.NullBool:
+		f.nullable, f.bind = true, (*Builder).parseBool
+		b.addFilterFieldsForBoolFields(f, f.bind.on(b))
+	case sql.NullTime, *sql.NullTime:
+		f.nullable, f.bind = true, (*Builder).parseDate
+		b.addFilterFieldsForNumericFields(f, f.bind.on(b))
 	default:
 		typ := reflect.TypeOf(v)
 		_, isStringer := v.(fmt.Stringer)
@@ -376,11 +2408,20 @@ func (b *Builder) parseField(field *structs.Field) {
 		dummyString := ""
 		switch {
 		case typ.ConvertibleTo(reflect.TypeOf(dummyString)), typ.ConvertibleTo(reflect.TypeOf(&dummyString)):
-			b.addStringField(colName, withSep, splitOnComma, wrapFn)
-		case typ.ConvertibleTo(reflect.TypeOf([]string{})):
-			b.addStringField(colName, withSep, splitOnComma, wrapFn)
+			b.addStringField(f)
+		case typ.ConvertibleTo(reflect.TypeOf([]string{})), typ.ConvertibleTo(reflect.TypeOf(&[]string{})):
+			b.addStringField(f)
+			b.addArrayField(f)
+		case isTextUnmarshaler(typ):
+			// i.e: uuid.UUID. the values are validated by the UnmarshalText method.
+			b.addFilterFieldsForTextFields(f, textParser(typ))
+		case typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 && b.Dialect == DialectPostgres:
+			b.addArrayField(f)
 		case isStringer:
-			b.addStringField(colName, withSep, splitOnComma, wrapFn)
+			b.addStringField(f)
+		case f.parse != nil:
+			// types that are not supported are usable with a custom parser.
+			b.addFilterFieldsForNumericFields(f, f.parse)
 		default:
 			panic(fmt.Sprintf("Could not use field %s (%T) with query filter", field.Name(), v))
 		}
@@ -388,22 +2429,322 @@ func (b *Builder) parseField(field *structs.Field) {
 	}
 }
 
+// wrapperOf returns the Wrapper of the given field value. the Wrapper may be implemented by
+// the value or by the pointer receiver, and the field may be a value or a pointer (possibly
+// nil). in these cases, the Wrapper is a new instance of the type.
+func wrapperOf(v interface{}) (Wrapper, bool) {
+	if w, ok := v.(Wrapper); ok && !isNilPtr(v) {
+		return w, true
+	}
+	typ := reflect.TypeOf(v)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	w, ok := reflect.New(typ).Interface().(Wrapper)
+	return w, ok
+}
+
+// valueWrapperOf returns the ValueWrapper of the given field value. see wrapperOf.
+func valueWrapperOf(v interface{}) (ValueWrapper, bool) {
+	if w, ok := v.(ValueWrapper); ok && !isNilPtr(v) {
+		return w, true
+	}
+	typ := reflect.TypeOf(v)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	w, ok := reflect.New(typ).Interface().(ValueWrapper)
+	return w, ok
+}
+
+// isNilPtr reports whether the given value is a nil pointer.
+func isNilPtr(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// relationType returns the struct type of a joined model field. struct types that are
+// used as values (i.e: time.Time, or types that implement fmt.Stringer) are not relations.
+// nil interface values have no type, and they are not relations either.
+func relationType(v interface{}) (reflect.Type, bool) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil, false
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isTime(typ) {
+		return nil, false
+	}
+	// types that are stored as a single column (e.g. sql.NullString) are not relations.
+	switch reflect.New(typ).Interface().(type) {
+	case fmt.Stringer, driver.Valuer:
+		return nil, false
+	}
+	return typ, true
+}
+
+// parseRelation registers the filters of a joined model field. the join follows the gorm
+// belongs-to conventions: the foreign key is the field name with an "ID" suffix (or the
+// "foreignkey" gorm tag), and it references the "id" column of the joined model (or the
+// "association_foreignkey" gorm tag). for example, "Owner Owner" on the Pet model is:
+//
+//	JOIN owners ON owners.id = pets.owner_id
+func (b *Builder) parseRelation(field *structs.Field, typ reflect.Type, gormOptions []string, rel relation) {
+	name := gorm.ToDBName(field.Name())
+	if param, ok := hasQueryParam(strings.Split(field.Tag(b.TagName), ",")); ok {
+		name = param
+	}
+	table := b.tableName(typ)
+	parent := rel.table
+	if parent == "" {
+		parent = b.tableName(reflect.TypeOf(b.Model))
+	}
+	foreignKey := gorm.ToDBName(field.Name() + "ID")
+	if key, ok := gormTag(gormOptions, "foreignkey"); ok {
+		foreignKey = gorm.ToDBName(key)
+	}
+	primaryKey := "id"
+	if key, ok := gormTag(gormOptions, "association_foreignkey"); ok {
+		primaryKey = gorm.ToDBName(key)
+	}
+	join := fmt.Sprintf("JOIN %[1]s ON %[1]s.%[2]s = %[3]s.%[4]s", table, primaryKey, parent, foreignKey)
+	// stop on cyclic relations. e.g: "owner.pet.owner".
+	for _, j := range rel.joins {
+		if j == join {
+			return
+		}
+	}
+	b.parseFields(structs.Fields(reflect.New(typ).Interface()), relation{
+		prefix: rel.prefix + name + b.JoinSeparator,
+		table:  table,
+		joins:  append(rel.joins[:len(rel.joins):len(rel.joins)], join),
+	})
+}
+
+// tableName returns the table name of the given model type, using the gorm conventions.
+func (b *Builder) tableName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if t, ok := reflect.New(typ).Interface().(interface{ TableName() string }); ok {
+		return t.TableName()
+	}
+	return inflection.Plural(gorm.ToDBName(typ.Name()))
+}
+
+// gormTag returns the value of the given key in the gorm struct tag options.
+// for example: "foreignkey:OwnerRefer".
+func gormTag(options []string, key string) (string, bool) {
+	for _, opt := range options {
+		kv := strings.SplitN(opt, ":", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), key) {
+			return strings.TrimSpace(kv[1]), true
+		}
+	}
+	return "", false
+}
+
+// isTime reports whether the given field type is time.Time or *time.Time.
+func isTime(typ reflect.Type) bool {
+	return typ == reflect.TypeOf(time.Time{}) || typ == reflect.TypeOf(&time.Time{})
+}
+
+// isTextUnmarshaler reports whether the pointer of the given field type implements
+// the encoding.TextUnmarshaler interface.
+func isTextUnmarshaler(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return reflect.PtrTo(typ).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// textParser returns a parser that decodes the values with the UnmarshalText method of
+// the given type. the canonical form (i.e: the String method) of the value is bound.
+func textParser(typ reflect.Type) parseFn {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return func(s string) (interface{}, bool) {
+		v := reflect.New(typ)
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return nil, false
+		}
+		if stringer, ok := v.Interface().(fmt.Stringer); ok {
+			return stringer.String(), true
+		}
+		return v.Elem().Interface(), true
+	}
+}
+
+// enumerator returns the Enumerator of the given field type, if it implements it.
+// pointer types are checked by their element type, since their value may be nil.
+func enumerator(typ reflect.Type) (Enumerator, bool) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	enum, ok := reflect.Zero(typ).Interface().(Enumerator)
+	return enum, ok
+}
+
+// enumValues returns the string representation of the enum values.
+func enumValues(enum Enumerator) map[string]bool {
+	values := make(map[string]bool)
+	for _, v := range enum.Enum() {
+		values[fmt.Sprint(v)] = true
+	}
+	return values
+}
+
+// enumParser returns a parser that accepts only the given enum values.
+func enumParser(values map[string]bool, parse parseFn) parseFn {
+	return func(s string) (interface{}, bool) {
+		if !values[s] {
+			return nil, false
+		}
+		return parse(s)
+	}
+}
+
 // addStringField adds all string filters to the given field.
-func (b *Builder) addStringField(colName, withSep string, splitOnComma bool, wrap WrapFn) {
-	b.addFilterField(colName, colName+" = ?", parseString, splitOnComma, wrap)
-	b.addFilterField(withSep+opEqual, colName+" = ?", parseString, splitOnComma, wrap)
-	b.addFilterField(withSep+opNotEqual, colName+" <> ?", parseString, splitOnComma, wrap)
-	b.addFilterField(withSep+opLike, colName+" LIKE ?", parseLikeString, splitOnComma, wrap)
+func (b *Builder) addStringField(f fieldOptions) {
+	b.addFilterField(f, "", "%s = ?", parseString)
+	b.addFilterField(f, opEqual, "%s = ?", parseString)
+	b.addFilterField(f, opNotEqual, b.notEqualFormat(f), parseString)
+	b.addFilterField(f, opIEqual, "LOWER(%s) = LOWER(?)", parseString)
+	b.addFilterField(f, opINotEqual, b.iNotEqualFormat(f), parseString)
+	b.addFilterField(f, opLike, "%s LIKE ?", parseLikeString)
+	b.addFilterField(f, opNotLike, "%s NOT LIKE ?", parseLikeString)
+	b.addFilterField(f, opILike, b.iLikeFormat(), parseLikeString)
+	b.addFilterField(f, opStartsWith, "%s LIKE ?", parsePrefixString)
+	b.addFilterField(f, opEndsWith, "%s LIKE ?", parseSuffixString)
+	if format, ok := regexFormats[b.Dialect]; ok {
+		b.addFilterField(f, opRegex, format, parseRegex)
+	}
+	b.addFilterField(f, opIn, "%s IN (?)", parseString)
+	b.addFilterField(f, opNotIn, "%s NOT IN (?)", parseString)
+}
+
+// addArrayField registers the array operators of slice fields on Postgres. "contains" matches
+// the arrays that overlap the given values, and "contains_all" matches the arrays that contain
+// all of them. i.e: "tags_contains=red,blue" is "tags && ?", bound to the '{"red","blue"}' array.
+func (b *Builder) addArrayField(f fieldOptions) {
+	if b.Dialect != DialectPostgres {
+		return
+	}
+	typ := f.typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// the values are a comma-separated list, and therefore, they are not split to
+	// multiple expressions.
+	f.splitOnComma = false
+	parse := arrayParser(typ.Elem())
+	b.addFilterField(f, opContains, "%s && ?", parse)
+	b.addFilterField(f, opContainsAll, "%s @> ?", parse)
+}
+
+// arrayParser returns a parser of comma-separated values to a Postgres array literal. the
+// values are validated by the parser of the element type. e.g: "a,b" is '{"a","b"}'.
+func arrayParser(elem reflect.Type) parseFn {
+	parse := parseString
+	switch elem.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parse = parseInt64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parse = parseUint64
+	case reflect.Float32, reflect.Float64:
+		parse = parseFloat
+	case reflect.Bool:
+		parse = parseBool
+	}
+	return func(s string) (interface{}, bool) {
+		values := strings.Split(s, ",")
+		for i := range values {
+			v, ok := parse(values[i])
+			if !ok {
+				return nil, false
+			}
+			values[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(fmt.Sprint(v)) + `"`
+		}
+		return "{" + strings.Join(values, ",") + "}", true
+	}
+}
+
+// addFilterField gets field options, operator, expression format and parse function, and
+// add it to the filterFields. an empty operator stands for the bare column name (equality).
+// operators that were disabled in the config are skipped.
+func (b *Builder) addFilterField(f fieldOptions, op, format string, parse parseFn) {
+	if b.operatorDisabled(op) {
+		return
+	}
+	name := f.name
+	if op != "" {
+		name += b.Separator + op
+	}
+	// a custom parser replaces the parser of the field type, and enum values are
+	// validated. pattern operators are excluded, since their values are patterns
+	// and not field values.
+	valueOp := parse != nil && !patternOp(op) && !arrayOp(op) && !foldOp(op)
+	custom := f.parse != nil && valueOp
+	if custom {
+		parse = f.parse
+	}
+	if f.enum != nil && valueOp {
+		parse = enumParser(f.enum, parse)
+	}
+	field := filterField{field: f.name, op: op, column: f.column, computed: f.computed, joins: f.joins, having: f.having, structField: f.structField, typ: f.typ, format: format, parse: parse, wrap: f.wrap, wrapValues: f.wrapValues, splitOnComma: f.splitOnComma, multiAnd: f.multiAnd}
+	// the parser of the field type is bound to each builder that uses the cached fields.
+	if f.bind != nil && parse != nil && !custom {
+		bind, enum := f.bind, f.enum
+		field.bind = func(b *Builder) parseFn {
+			parse := bind.on(b)
+			if enum != nil && valueOp {
+				parse = enumParser(enum, parse)
+			}
+			return parse
+		}
+	}
+	if b.Dialect == DialectPostgres && b.LikeAnyArray {
+		field.anyFormat = likeAnyFormats[op]
+	}
+	b.filterFields[name] = field
+}
+
+// patternOp reports whether the given operator matches its values as LIKE patterns.
+func patternOp(op string) bool {
+	switch op {
+	case opLike, opNotLike, opILike, opStartsWith, opEndsWith, opRegex:
+		return true
+	default:
+		return false
+	}
+}
+
+// arrayOp reports whether the given operator compares array columns to a list of values.
+func arrayOp(op string) bool {
+	return op == opContains || op == opContainsAll
+}
+
+// foldOp reports whether the given operator compares the values case-insensitively. the enum
+// values are not enforced on these operators, since they are case-sensitive.
+func foldOp(op string) bool {
+	return op == opIEqual || op == opINotEqual
 }
 
-// addFilterField gets field name, expression (format) and parse function, and
-// add it to the filterFields.
-func (b *Builder) addFilterField(name, format string, parse parseFn, splitOnComma bool, wrap ...WrapFn) {
-	wrapFn := nopWrapper
-	if len(wrap) != 0 {
-		wrapFn = wrap[0]
+// operatorDisabled reports whether the given operator was disabled in the config.
+// disabling the "eq" operator disables the bare column name as well.
+func (b *Builder) operatorDisabled(op string) bool {
+	if op == "" {
+		op = opEqual
+	}
+	for _, disabled := range b.DisabledOperators {
+		if disabled == op {
+			return true
+		}
 	}
-	b.filterFields[name] = filterField{exp: format, parse: parse, wrap: wrapFn, splitOnComma: splitOnComma}
+	return false
 }
 
 // hasQueryParam return the custom param if there is one.
@@ -416,6 +2757,27 @@ func hasQueryParam(l []string) (string, bool) {
 	return "", false
 }
 
+// gormColumn returns the column name of the gorm tag options, if there is one. i.e: "column:usr_name".
+func gormColumn(l []string) (string, bool) {
+	for _, s := range l {
+		kv := strings.SplitN(strings.TrimSpace(s), ":", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "column") && kv[1] != "" {
+			return kv[1], true
+		}
+	}
+	return "", false
+}
+
+// tagOption returns the value of a "key=value" option in the given list.
+func tagOption(l []string, key string) (string, bool) {
+	for _, s := range l {
+		if strings.HasPrefix(s, key+"=") {
+			return strings.TrimPrefix(s, key+"="), true
+		}
+	}
+	return "", false
+}
+
 // contains test if string is in the given list.
 func contains(l []string, s string) bool {
 	for i := range l {
@@ -443,6 +2805,39 @@ func parseInt64(s string) (interface{}, bool) {
 	return n, err == nil
 }
 
+func parseInt32(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	n, err := strconv.ParseInt(s, 10, 32)
+	return int32(n), err == nil
+}
+
+// parseFloat parses float64 and float32 values. NaN and infinity are not valid filter values.
+func parseFloat(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+func parseUint(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	n, err := strconv.ParseUint(s, 10, strconv.IntSize)
+	return uint(n), err == nil
+}
+
+func parseUint64(s string) (interface{}, bool) {
+	if s == "" {
+		return nil, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	return n, err == nil
+}
+
 func parseString(s string) (interface{}, bool) {
 	return s, s != ""
 }
@@ -451,19 +2846,105 @@ func parseLikeString(s string) (interface{}, bool) {
 	return "%" + s + "%", s != ""
 }
 
-func parseDate(s string) (interface{}, bool) {
-	t, err := time.Parse(time.RFC3339, s)
-	return t, err == nil
+// regexFormats are the formats of the regex operator in the dialects that support it.
+var regexFormats = map[string]string{
+	DialectPostgres: "%s ~ ?",
+	DialectMySQL:    "%s REGEXP ?",
 }
 
-func parseDatePointer(s string) (interface{}, bool) {
-	t, ok := parseDate(s)
+// parseRegex validates that the pattern compiles, in order to reject invalid patterns before
+// they reach the database. note that the database syntax may differ in some features.
+func parseRegex(s string) (interface{}, bool) {
+	_, err := regexp.Compile(s)
+	return s, s != "" && err == nil
+}
+
+func parsePrefixString(s string) (interface{}, bool) {
+	return s + "%", s != ""
+}
+
+func parseSuffixString(s string) (interface{}, bool) {
+	return "%" + s, s != ""
+}
+
+// parseDate parses a time in one of the TimeFormats layouts, in their order. if none of
+// them matches, an all-digit input is parsed as Unix seconds (e.g. "1577836800").
+// the parsed time is converted to the configured Location, if there is one.
+func (b *Builder) parseDate(s string) (interface{}, bool) {
+	t, ok := b.parseTime(s)
+	if ok && b.Location != nil {
+		t = t.In(b.Location)
+	}
+	return t, ok
+}
+
+// parseTime parses the input by the TimeFormats layouts or as Unix seconds.
+func (b *Builder) parseTime(s string) (time.Time, bool) {
+	for _, layout := range b.TimeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0).UTC(), true
+}
+
+func (b *Builder) parseDatePointer(s string) (interface{}, bool) {
+	t, ok := b.parseDate(s)
 	if !ok {
 		return nil, false
 	}
 	return &t, true
 }
 
+// parseBool parses a boolean, including the tokens of Config.BoolValues.
+func (b *Builder) parseBool(s string) (interface{}, bool) {
+	if v, ok := b.BoolValues[s]; ok {
+		return strconv.FormatBool(v), true
+	}
+	return parseBool(s)
+}
+
+// parseDuration parses a duration in Go format (e.g. "1h30m"), or in ISO 8601
+// format (e.g. "PT1H30M") if AllowISODurations is enabled.
+func (b *Builder) parseDuration(s string) (interface{}, bool) {
+	if b.AllowISODurations && strings.HasPrefix(s, "P") {
+		return parseISODuration(s)
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil
+}
+
+// isoDuration matches the fixed-length components of ISO 8601 durations: weeks, days,
+// hours, minutes and seconds. years and months are not supported, since their length varies.
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses an ISO 8601 duration. for example: "PT30S" or "P1DT12H".
+func parseISODuration(s string) (interface{}, bool) {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return nil, false
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return nil, false
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, true
+}
+
 func parseBool(s string) (interface{}, bool) {
 	if s == "" {
 		return nil, false
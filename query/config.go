@@ -1,29 +1,99 @@
 package query
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 const (
 	// fields in the struct tag.
 	sortTag     = "sort"
+	sortDescTag = "sortdesc"
+	groupTag    = "group"
 	splitTag    = "split"
+	noSplitTag  = "nosplit"
 	filterTag   = "filter"
 	paramTag    = "param"
+	columnTag   = "column"
+	multiTag    = "multi"
 	detailedTag = "detailed"
-	// search param in query string.
-	searchParam = "search"
+	noSelectTag = "noselect"
+	jsonTag     = "json"
+	// distinct param in query string. see Config.AllowDistinct.
+	distinctParam = "distinct"
 	// operators in query string.
 	opEqual              = "eq"
 	opNotEqual           = "neq"
+	opIEqual             = "ieq"
+	opINotEqual          = "ineq"
 	opLike               = "like"
+	opNotLike            = "not_like"
+	opILike              = "ilike"
+	opStartsWith         = "starts_with"
+	opEndsWith           = "ends_with"
+	opRegex              = "re"
+	opContains           = "contains"
+	opContainsAll        = "contains_all"
 	opLessThan           = "lt"
 	opGreaterThan        = "gt"
 	opLessThanOrEqual    = "lte"
 	opGreaterThanOrEqual = "gte"
+	opIn                 = "in"
+	opNotIn              = "not_in"
+	opNull               = "null"
+	opBetween            = "between"
+)
+
+// Dialects that are supported by the Builder. see Config.Dialect.
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+// Positions of NULL values in the sort order. see Config.SortNulls.
+const (
+	NullsFirst = "first"
+	NullsLast  = "last"
 )
 
+// arity is the number of values that an operator expects in each param argument. the
+// arguments of operators that take more than one value are split on commas. max is -1
+// if there's no upper limit.
+type arity struct {
+	min, max int
+	// single indicates that the param can be given only once.
+	single bool
+}
+
+// operatorArity holds the arity of the operators. operators that are missing from the
+// map (i.e: eq, like, gt) expect exactly one value in each argument.
+var operatorArity = map[string]arity{
+	opNull:    {min: 1, max: 1, single: true},
+	opBetween: {min: 2, max: 2},
+	opIn:      {min: 1, max: -1},
+	opNotIn:   {min: 1, max: -1},
+}
+
+// Placeholder styles of the bind parameters. see Config.Placeholder.
+const (
+	PlaceholderQuestion = "?"
+	PlaceholderDollar   = "$"
+)
+
+// likeAnyFormats are the formats of multi-value like operators on Postgres.
+var likeAnyFormats = map[string]string{
+	opLike:       "%s LIKE ANY(ARRAY[%s])",
+	opNotLike:    "%s NOT LIKE ANY(ARRAY[%s])",
+	opILike:      "%s ILIKE ANY(ARRAY[%s])",
+	opStartsWith: "%s LIKE ANY(ARRAY[%s])",
+	opEndsWith:   "%s LIKE ANY(ARRAY[%s])",
+}
+
 // An expression can be optionally prefixed with + or - to control the sorting direction,
 // ascending or descending. For example, '+field' or '-field'.
-// If the predicate is missing or empty then it defaults to '+'
+// If the predicate is missing or empty then it defaults to Config.DefaultOrderDirection ('+'),
+// or to descending order for the fields with the "sortdesc" option. i.e: `query:"sort,sortdesc"`.
 var sortDirections = map[byte]string{'+': "asc", '-': "desc"}
 
 // Config for the Builder constructor.
@@ -35,6 +105,9 @@ type Config struct {
 	TagName string
 	// Separator between field and command. defaults to "_".
 	Separator string
+	// SplitOnComma - if true, the values of all filter fields are split on commas, as if
+	//    they had the "split" option. a field can opt out with the "nosplit" option.
+	SplitOnComma bool
 	// IgnoreSort indicates if the builder should skip the sort process.
 	IgnoreSort bool
 	// SortParam is the name of the sort parameter.
@@ -42,7 +115,28 @@ type Config struct {
 	SortParam string
 	// DefaultSort is the default sort string for the query builder.
 	// if the builder gets and empty sort parameter it'll add this default.
+	// its columns must be columns of the model. i.e: "created_at desc, id".
 	DefaultSort string
+	// StableSortKey is a unique column (i.e: "id") that is appended to the sort of the query,
+	// if it's not already there. it makes the order of rows with equal sort values
+	// deterministic, and therefore, the pagination stable. i.e: "created_at desc, id".
+	StableSortKey string
+	// MaxSortFields is the maximum number of fields in the sort param. defaults to 0 (unlimited).
+	MaxSortFields int
+	// AllowDuplicateSort allows a field to appear more than once in the sort param. by default,
+	// "sort=name&sort=-name" is rejected, since its ORDER BY clause is ambiguous.
+	AllowDuplicateSort bool
+	// AllowDistinct enables the "distinct" param. "distinct=true" prepends DISTINCT to the
+	// select of the query. i.e: "DISTINCT id,name" with ExplicitSelect, or "DISTINCT *".
+	// if it's disabled, the param is not recognized (and rejected with StrictParams).
+	AllowDistinct bool
+	// GroupParam is the name of the group parameter. the fields with the "group" option
+	// can be grouped by. i.e: "group=owner_id&group=status". defaults to "group".
+	GroupParam string
+	// GroupBy is the default GROUP BY columns of the query, that are used if the group
+	// parameter is missing. i.e: "owner_id, status". the select of a grouped query is
+	// its group columns (see DBQuery.GroupBy).
+	GroupBy string
 	// LimitParam is the name of the limit parameter in the query string.
 	// defaults to "limit".
 	LimitParam string
@@ -50,9 +144,56 @@ type Config struct {
 	DefaultLimit int
 	// LimitMaxValue is the maximum value that accept valid parameter.
 	LimitMaxValue int
+	// ClampLimit - if true, a limit above LimitMaxValue is reduced to LimitMaxValue instead
+	//    of failing the parsing, and DBQuery.LimitClamped is set. useful for informing the
+	//    client in a response header. e.g: "Warning: 199 - limit reduced to 100".
+	//    invalid or negative limits fail the parsing in both modes.
+	ClampLimit bool
+	// AllowUnlimited - if true, an explicit "limit=0" is reported as a request for all rows
+	//    with Pagination.Unlimited. in both modes, the DefaultLimit is not applied to a zero
+	//    limit, and DBQuery.Limit is left at 0 (which Apply skips). note that LimitMaxValue and
+	//    ClampLimit bound only the positive limits.
+	AllowUnlimited bool
 	// OffsetParam is the name of the offset parameter in the query string.
 	// defaults to "offset"
 	OffsetParam string
+	// OffsetMaxValue is the maximum value of the offset parameter. it guards against deep
+	// pagination, that may cause slow scans of the table. defaults to 0 (unlimited).
+	OffsetMaxValue int
+	// JoinSeparator separates the name of a joined model field from the names of its fields
+	// in the filter params. defaults to ".". i.e: "owner.name_like".
+	JoinSeparator string
+	// OrParam is the name of the param that groups filters with OR. it's disabled by default.
+	// each value of the param is a filter param and its value, separated by a colon, and it's
+	// parsed and validated like the filter param. the group is combined with the other filters
+	// with AND. i.e: with "or[]", "status=open&or[]=name:a8m&or[]=age_gt:30" is
+	// "status = ? AND (name = ? OR age > ?)".
+	OrParam string
+	// MaxOrGroups limits the number of terms in the OR group of a query (see OrParam). each
+	//    term is a condition of the group, and therefore, it bounds the size of the group
+	//    expression. larger groups are rejected with a ParseError. zero means no limit.
+	MaxOrGroups int
+	// MaxJoinDepth limits the nesting of the joined model filters. i.e: "owner.name" has a
+	//    depth of 1, and "owner.address.city" has a depth of 2. deeper filters are rejected
+	//    with a ParseError. zero means no limit.
+	MaxJoinDepth int
+	// MaxJoins limits the number of distinct joins in a query. zero means no limit.
+	MaxJoins int
+	// CursorField enables keyset pagination on the given filter field (column name). when
+	// the cursor param is present, the query returns the rows after the given value, ordered
+	// by the field. i.e: "after=42" is "WHERE id > 42 ORDER BY id asc". the offset and sort
+	// params are not used with a cursor.
+	CursorField string
+	// CursorParam is the name of the cursor parameter in the query string.
+	// defaults to "after".
+	CursorParam string
+	// BeforeParam is the name of the backward cursor parameter. "before=42" returns the rows
+	// before the given value, in descending order. see DBQuery.PrevCursor and NaturalOrder.
+	// defaults to "before".
+	BeforeParam string
+	// SearchParam is the name of the search parameter of models that implement the
+	// Searcher interface. defaults to "search".
+	SearchParam string
 	// SearchOperator used to combine search condition together. defaults to "AND".
 	SearchOperator string
 	// ExplicitSelect - if true, the query will select the relevant specific columns.
@@ -61,6 +202,142 @@ type Config struct {
 	// OnlySelectNonDetailedFields - if true will select only the non 'detailed' fields
 	//    true implies ExplicitSelect = true
 	OnlySelectNonDetailedFields bool
+	// NullSafeNeq - if true, the "neq" operator on nullable (pointer) fields also
+	//    matches NULL values. i.e: "(status <> ? OR status IS NULL)"
+	NullSafeNeq bool
+	// CaseInsensitiveParams - if true, the param names are matched case-insensitively.
+	//    i.e: "Limit" or "AGE_GT" are treated as "limit" and "age_gt".
+	CaseInsensitiveParams bool
+	// BaseConditions are conditions that are added to every query, in addition to
+	// the client filters. a condition with an OptOutParam is dropped when the client
+	// sets this param to true. i.e: "include_archived=true".
+	BaseConditions []Condition
+	// MultiColumnFilters maps a param name to multiple filters that are combined with "OR".
+	// each ColumnFilter references a registered filter of the model. for example:
+	//
+	//	"updated": {{Column: "updated_at", Op: "gte"}, {Column: "created_at", Op: "gte"}}
+	//
+	// makes "updated=2020-01-01T00:00:00Z" to be "(updated_at >= ? OR created_at >= ?)".
+	MultiColumnFilters map[string][]ColumnFilter
+	// DisabledOperators are operators that are not registered for any of the fields.
+	// params with these operators are treated as unknown params. i.e: []string{"like"}.
+	DisabledOperators []string
+	// AllowISODurations - if true, time.Duration fields accept ISO 8601 durations
+	//    (i.e: "PT1H30M"), in addition to the Go format (i.e: "1h30m").
+	AllowISODurations bool
+	// BoolValues are additional tokens that boolean fields accept, and their values. the
+	// tokens are matched exactly, and they are bound as "true" or "false". for example:
+	//
+	//	BoolValues: map[string]bool{"yes": true, "no": false, "on": true, "off": false}
+	//
+	// the values that are accepted by strconv.ParseBool are always accepted.
+	BoolValues map[string]bool
+	// TimeFormats are the layouts that are accepted by the time fields, in the order they
+	// are tried. defaults to RFC3339. i.e: []string{time.RFC3339, "2006-01-02"}. input that
+	// does not match any of them, and contains only digits, is parsed as Unix seconds.
+	TimeFormats []string
+	// Location is an optional location that the parsed times are converted to. i.e: time.UTC
+	// converts "2020-01-02T03:00:00+03:00" to "2020-01-02T00:00:00Z". by default, the offset
+	// of the input is kept.
+	Location *time.Location
+	// ReservedParams are params that the builder treats as known, but ignores. it lets
+	// the application layer its own params (i.e: "include", "expand") on top of the
+	// builder, without having them reported as unknown params.
+	ReservedParams []string
+	// Dialect is the SQL dialect of the database. used for dialect specific expressions.
+	// one of: DialectPostgres or DialectMySQL. defaults to standard SQL expressions.
+	Dialect string
+	// LikeAnyArray - if true and the Dialect is Postgres, like filters with multiple values
+	//    are combined with "ANY" instead of "OR". i.e: "name LIKE ANY(ARRAY[?, ?])"
+	LikeAnyArray bool
+	// Placeholder is the style of the bind parameters in the query expressions. one of:
+	// PlaceholderQuestion ("name = ? AND age > ?") or PlaceholderDollar ("name = $1 AND
+	// age > $2"). defaults to PlaceholderQuestion, which is the style gorm expects.
+	// the numbering is done on the assembled expressions, so templates, wrappers and
+	// Searcher implementations keep using "?". list values get a placeholder for each
+	// value (i.e: "id IN ($1, $2)"), and the cursor is numbered after the where values.
+	Placeholder string
+	// FieldParsers maps column names to custom parse functions, that override the default
+	// parser of the field type. the columns of joined models are qualified with their table
+	// name (i.e: "owners.name"), and a column that is not a filter field fails NewBuilder.
+	// the function returns the value that is bound to the query, or false if the input is
+	// invalid. for example, for validating an enum field:
+	//
+	//	"status": func(s string) (interface{}, bool) { return s, s == "active" || s == "idle" }
+	//
+	// the parser is used by all operators of the field, except for the pattern operators
+	// (like and ilike). fields of unsupported types get the numeric operators.
+	FieldParsers map[string]func(string) (interface{}, bool)
+	// ColumnUsageHook is an optional function that is called by Parse with the distinct
+	// columns that are filtered or sorted by the query (including the default sort). it's
+	// called only for valid queries, and it's useful for collecting index usage statistics.
+	ColumnUsageHook func(columns []string)
+	// BareRangeForTime - if true, two values of a bare time field are treated as a range,
+	//    instead of being combined with "OR". i.e: "created_at=t1&created_at=t2" is
+	//    "created_at BETWEEN ? AND ?". one value is still an equality.
+	BareRangeForTime bool
+	// DoubleDecodeValues - if true, the filter values are unescaped once more before they
+	//    are parsed. useful behind proxies that encode the values twice. i.e: "a%2520b".
+	//    values that fail the extra decoding are used as is.
+	DoubleDecodeValues bool
+	// ComputedSorts maps sort keys to server-defined sort expressions. it lets clients
+	// sort by an expression, and combine it with the column sorts. for example:
+	//
+	//	"relevance": {Exp: "ts_rank(document, to_tsquery(?))", Vals: []interface{}{"pet"}}
+	//
+	// makes "sort=-relevance&sort=name" to be "ts_rank(document, to_tsquery(?)) desc, name".
+	ComputedSorts map[string]ComputedSort
+	// SortExpressions maps sort keys to raw SQL expressions without arguments. it's a shorthand
+	// for ComputedSorts, and the expressions are parenthesized. for example:
+	//
+	//	"score": "upvotes - downvotes"
+	//
+	// makes "sort=-score" to be "(upvotes - downvotes) desc".
+	SortExpressions map[string]string
+	// ComputedFilters maps virtual string filters to server-defined expressions. the
+	// filters are registered with the string operators (eq, neq, like, ...). for example:
+	//
+	//	"full_name": "first_name || ' ' || last_name"
+	//
+	// makes "full_name_like=john doe" to be "(first_name || ' ' || last_name) LIKE ?".
+	// the names can not be filters of the model.
+	ComputedFilters map[string]string
+	// HavingFields maps filters to aggregate expressions, that are added to the HAVING clause
+	// of the query, instead of the WHERE clause. the filters are registered with the numeric
+	// operators. for example:
+	//
+	//	"pet_count": "COUNT(*)"
+	//
+	// makes "pet_count_gt=2" to be "HAVING COUNT(*) > ?". it's used with grouped queries.
+	// the names can not be filters of the model, or computed filters.
+	HavingFields map[string]string
+	// SortNulls maps sort fields (or computed sorts) to the position of NULL values in their
+	// sort order. one of: NullsFirst or NullsLast. for example, "updated_at": NullsLast makes
+	// "sort=-updated_at" to be "updated_at desc NULLS LAST". it's ignored on MySQL, since the
+	// dialect doesn't support it.
+	SortNulls map[string]string
+	// StrictParams - if true, Parse fails on params that are not recognized by the builder
+	//    (see Builder.UnknownParams), instead of ignoring them. i.e: "?nam=foo".
+	StrictParams bool
+	// RequireTaggedFields - if true, NewBuilder fails with ErrNoTaggedFields if the model has
+	//    no filter or sort fields. i.e: the struct tags are missing, or the TagName is wrong.
+	RequireTaggedFields bool
+	// DefaultOrderDirection is the direction of sort fields that are not prefixed by an
+	// order indicator. one of: "asc" or "desc". defaults to "asc".
+	DefaultOrderDirection string
+}
+
+// ComputedSort is a sort expression with its arguments.
+type ComputedSort struct {
+	Exp  string
+	Vals []interface{}
+}
+
+// ColumnFilter is a filter of a model field, identified by its column name and operator.
+// an empty Op stands for the bare column filter (equality).
+type ColumnFilter struct {
+	Column string
+	Op     string
 }
 
 func (c *Config) defaults() error {
@@ -70,11 +347,27 @@ func (c *Config) defaults() error {
 	defaultString(&c.TagName, "query")
 	defaultString(&c.Separator, "_")
 	defaultString(&c.SortParam, "sort")
+	defaultString(&c.GroupParam, "group")
 	defaultString(&c.LimitParam, "limit")
 	defaultString(&c.OffsetParam, "offset")
+	defaultString(&c.CursorParam, "after")
+	defaultString(&c.BeforeParam, "before")
+	defaultString(&c.JoinSeparator, ".")
+	defaultString(&c.SearchParam, "search")
 	defaultString(&c.SearchOperator, "AND")
 	defaultInt(&c.DefaultLimit, 25)
 	defaultInt(&c.LimitMaxValue, 100)
+	defaultString(&c.Placeholder, PlaceholderQuestion)
+	if c.Placeholder != PlaceholderQuestion && c.Placeholder != PlaceholderDollar {
+		return fmt.Errorf("query: invalid 'Placeholder' value: '%s'", c.Placeholder)
+	}
+	if len(c.TimeFormats) == 0 {
+		c.TimeFormats = []string{time.RFC3339}
+	}
+	defaultString(&c.DefaultOrderDirection, "asc")
+	if c.DefaultOrderDirection != "asc" && c.DefaultOrderDirection != "desc" {
+		return fmt.Errorf("query: invalid 'DefaultOrderDirection' value: '%s'", c.DefaultOrderDirection)
+	}
 	return nil
 }
 
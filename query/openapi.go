@@ -0,0 +1,188 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// OpenAPIParameters returns the query parameters definitions of the given builder.
+// the result contains the control params (limit, offset, sort and search), followed
+// by all registered filters, sorted by their names. it can be merged into the
+// generated spec, in order to keep the documented API in sync with the model tags.
+func OpenAPIParameters(b *Builder) []spec.Parameter {
+	offset := spec.QueryParam(b.OffsetParam).
+		Typed("integer", "int32").
+		WithDescription("number of items to skip").
+		WithMinimum(0, false)
+	if b.OffsetMaxValue > 0 {
+		offset.WithMaximum(float64(b.OffsetMaxValue), false)
+	}
+	// a zero limit selects all the items, or the default number of items (see
+	// Config.AllowUnlimited), and limits above the maximum are clamped with ClampLimit.
+	limit := spec.QueryParam(b.LimitParam).
+		Typed("integer", "int32").
+		WithDescription("maximum number of items to return").
+		WithDefault(b.DefaultLimit).
+		WithMinimum(0, false)
+	if !b.ClampLimit {
+		limit.WithMaximum(float64(b.LimitMaxValue), false)
+	}
+	params := []spec.Parameter{*limit, *offset}
+	if b.CursorField != "" {
+		typ, format := swaggerType(b.cursorField.typ)
+		params = append(params, *spec.QueryParam(b.CursorParam).
+			Typed(typ, format).
+			WithDescription(fmt.Sprintf("return the items after the given %s (keyset pagination)", b.CursorField)))
+		params = append(params, *spec.QueryParam(b.BeforeParam).
+			Typed(typ, format).
+			WithDescription(fmt.Sprintf("return the items before the given %s (keyset pagination)", b.CursorField)))
+	}
+	if !b.IgnoreSort {
+		params = append(params, *b.sortParameter())
+	}
+	if len(b.groupFields) > 0 {
+		fields := make([]string, 0, len(b.groupFields))
+		for name := range b.groupFields {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		params = append(params, *spec.QueryParam(b.GroupParam).
+			CollectionOf(spec.NewItems().Typed("string", ""), "multi").
+			WithDescription("group by fields: " + strings.Join(fields, ", ")))
+	}
+	if b.AllowDistinct {
+		params = append(params, *spec.QueryParam(distinctParam).
+			Typed("boolean", "").
+			WithDescription("return only distinct rows"))
+	}
+	if b.searchable() {
+		params = append(params, *spec.QueryParam(b.SearchParam).
+			CollectionOf(spec.NewItems().Typed("string", ""), "multi").
+			WithDescription("free text search"))
+	}
+	if b.OrParam != "" {
+		params = append(params, *spec.QueryParam(b.OrParam).
+			CollectionOf(spec.NewItems().Typed("string", ""), "multi").
+			WithDescription("filters that are combined with OR. i.e: 'name:a8m'"))
+	}
+	names := make([]string, 0, len(b.filterFields)+len(b.MultiColumnFilters))
+	for name := range b.filterFields {
+		names = append(names, name)
+	}
+	for name := range b.MultiColumnFilters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := b.filterFields[name]
+		if !ok {
+			// multi-column filters share the type of their first column.
+			field = b.multiColumnFields[name][0]
+		}
+		params = append(params, *filterParameter(name, field))
+	}
+	return params
+}
+
+// sortParameter returns the definition of the sort param. the allowed values are the
+// sortable fields and the computed sorts, optionally prefixed by an order indicator.
+func (b *Builder) sortParameter() *spec.Parameter {
+	fields := make([]string, 0, len(b.sortFields)+len(b.ComputedSorts)+len(b.SortExpressions))
+	for name := range b.sortFields {
+		fields = append(fields, name)
+	}
+	for name := range b.ComputedSorts {
+		fields = append(fields, name)
+	}
+	for name := range b.SortExpressions {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	desc := "sort order. use the '-' prefix for descending order"
+	if len(fields) > 0 {
+		desc += ". sortable fields: " + strings.Join(fields, ", ")
+	}
+	return spec.QueryParam(b.SortParam).
+		CollectionOf(spec.NewItems().Typed("string", ""), "multi").
+		WithDescription(desc)
+}
+
+// filterParameter returns the definition of the filter param with the given name.
+func filterParameter(name string, field filterField) *spec.Parameter {
+	p := spec.QueryParam(name)
+	typ, format := swaggerType(field.typ)
+	switch field.op {
+	case opNull:
+		return p.Typed("boolean", "").WithDescription(fmt.Sprintf("filter %s by null value", field.column))
+	case opIn, opNotIn:
+		p.CollectionOf(spec.NewItems().Typed(typ, format), "csv")
+	case opBetween:
+		p.CollectionOf(spec.NewItems().Typed(typ, format), "csv").WithMinItems(2).WithMaxItems(2)
+	case opLike, opNotLike, opILike, opStartsWith, opEndsWith, opRegex:
+		p.Typed("string", "")
+	case opContains, opContainsAll:
+		elem := field.typ
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		p.CollectionOf(spec.NewItems().Typed(swaggerType(elem.Elem())), "csv")
+	default:
+		p.Typed(typ, format)
+	}
+	op := field.op
+	if op == "" {
+		op = opEqual
+	}
+	return p.WithDescription(fmt.Sprintf("filter %s by the %q operator", field.column, op))
+}
+
+// sqlNullTypes maps the sql.Null* types to the types of their values.
+var sqlNullTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullTime{}):    reflect.TypeOf(time.Time{}),
+}
+
+// swaggerType returns the swagger type and format of the given field type.
+func swaggerType(typ reflect.Type) (string, string) {
+	if typ == nil {
+		return "string", ""
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if inner, ok := sqlNullTypes[typ]; ok {
+		typ = inner
+	}
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		return "string", "date-time"
+	case typ == reflect.TypeOf(time.Duration(0)):
+		return "string", "duration"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean", ""
+	case reflect.Int, reflect.Int64:
+		return "integer", "int64"
+	case reflect.Int32:
+		return "integer", "int32"
+	case reflect.Uint, reflect.Uint64:
+		return "integer", "uint64"
+	case reflect.Float64:
+		return "number", "double"
+	case reflect.Float32:
+		return "number", "float"
+	default:
+		return "string", ""
+	}
+}
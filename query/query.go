@@ -1,6 +1,12 @@
 package query
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/jinzhu/gorm"
 )
 
@@ -8,10 +14,21 @@ import (
 type DBQuery struct {
 	// the number of rows returned by the SELECT statement.
 	Limit int
+	// LimitClamped indicates that the requested limit exceeded the maximum value,
+	// and was reduced to it. see Config.ClampLimit.
+	LimitClamped bool
 	// start querying from offset x. used for pagination.
 	Offset int
+	// Cursor is the keyset pagination cursor of the query, if there is one.
+	// see Config.CursorField.
+	Cursor *Cursor
 	// used as a parameter for the gorm.Order method. example: "age desc, name"
 	Sort string
+	// SortVal are the arguments of computed sort expressions in Sort, if there are any.
+	// gorm binds them after the arguments of the where statement (CondVal).
+	SortVal []interface{}
+	// SortFields are the fields of the Sort expression in their order.
+	SortFields []SortField
 	// CondExp and CondVal come together and used as a parameters for the gorm.Where
 	// method.
 	//
@@ -26,18 +43,188 @@ type DBQuery struct {
 	// 	   Val: "a8m", 22
 	CondExp string
 	CondVal []interface{}
+	// GroupBy is used as a parameter for the gorm.Group method. example: "owner_id, status".
+	// the Select of a grouped query is set to its group columns.
+	GroupBy string
+	// HavingExp and HavingVal are the conditions on aggregates, and they are used as
+	// parameters for the gorm.Having method. see Config.HavingFields.
+	HavingExp string
+	HavingVal []interface{}
+	// Clauses are the conditions of CondExp and CondVal, one for each filter
+	// or added expression. used by the ApplyStructured method.
+	Clauses []Clause
+	// AppliedFilters are the sorted columns that are filtered by the query params. useful
+	// for audit logs or for building cache keys. e.g: []string{"age", "name"}.
+	AppliedFilters []string
+	// Joins are the JOIN clauses that are required by the filters on joined models.
+	// for example: "JOIN owners ON owners.id = pets.owner_id".
+	Joins []string
 	// Select specify fields that you want to retrieve from database when querying.
 	// the default is to select all fields.
 	//
 	//	Select: "DISTINCT id"
 	Select string
+	// Pagination is the effective pagination of the query, including whether
+	// the default values were applied. useful for response metadata.
+	Pagination Pagination
+	// ored indicates that the last condition was added with Or, and therefore, CondExp needs
+	// to be parenthesized before adding a condition with And.
+	ored bool
+	// dollar indicates that the placeholders of the query are numbered. see Config.Placeholder.
+	dollar bool
+	// cursorField is the struct field of the cursor. it's used for reading the cursor
+	// value of a row. see PrevCursor.
+	cursorField string
+}
+
+// Pagination describes the pagination that was applied to the query.
+type Pagination struct {
+	// Limit and Offset are the effective values, even if the defaults were used.
+	Limit  int
+	Offset int
+	// DefaultLimit and DefaultOffset indicate if the default values were applied
+	// because the parameters were missing from the request.
+	DefaultLimit  bool
+	DefaultOffset bool
+	// Unlimited indicates that the client requested all rows with an explicit
+	// zero limit. see Config.AllowUnlimited.
+	Unlimited bool
 }
 
-// Apply applies the query input on a database instance
+// SortField is a field in the sort expression of the query.
+type SortField struct {
+	// Column is the column name, or the name of the computed sort.
+	Column string
+	Desc   bool
+}
+
+// Cursor is a keyset pagination cursor. the query returns the rows with
+// a Column value greater than Value, or less than Value if Before is set.
+type Cursor struct {
+	Column string
+	Value  interface{}
+	// Before indicates a backward cursor. the rows are returned in descending order,
+	// and NaturalOrder restores their order.
+	Before bool
+}
+
+// Condition is a server-defined where condition.
+type Condition struct {
+	// Exp and Vals are used as a parameters for the gorm.Where method.
+	// example: Exp: "archived = ?", Vals: false
+	Exp  string
+	Vals []interface{}
+	// OptOutParam is an optional name of a boolean param that lets the client drop
+	// this condition from the query. example: "include_archived".
+	OptOutParam string
+}
+
+// Clause is a single condition of the query where statement.
+type Clause struct {
+	Exp  string
+	Vals []interface{}
+}
+
+// Apply applies the query input on a database instance, and returns the chainable
+// *gorm.DB without executing it. The caller can add its own options (e.g. Joins or Preload)
+// before executing the query with a finisher method, like Find or Count.
 func (q *DBQuery) Apply(db *gorm.DB) *gorm.DB {
 	if q == nil {
 		return db
 	}
+	db = q.applyOptions(db)
+	if q.CondExp != "" {
+		db = db.Where(q.CondExp, q.CondVal...)
+	}
+	return q.applyCursor(db)
+}
+
+// ApplyStructured is like Apply, but it issues a separate gorm.Where call for each clause,
+// instead of one call with the combined CondExp. It's useful for gorm plugins that need to
+// see the individual conditions. If the query has no clauses (e.g. CondExp was set directly),
+// it falls back to the combined expression.
+func (q *DBQuery) ApplyStructured(db *gorm.DB) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	db = q.applyOptions(db)
+	if len(q.Clauses) == 0 {
+		if q.CondExp != "" {
+			db = db.Where(q.CondExp, q.CondVal...)
+		}
+		return q.applyCursor(db)
+	}
+	for _, c := range q.Clauses {
+		db = db.Where(c.Exp, c.Vals...)
+	}
+	return q.applyCursor(db)
+}
+
+// ApplyCount applies the joins, the select and the where statement of the query on a
+// database instance, without the limit, offset and sort options. It's used for counting the
+// total number of rows that match the query. for example:
+//
+//	var total int
+//	q.ApplyCount(db.Model(&Pet{})).Count(&total)
+//
+// grouped queries are counted by their groups (that match the HAVING clause), and distinct
+// queries by their distinct rows, and therefore, the query is wrapped in a subquery. i.e:
+//
+//	SELECT count(*) FROM (SELECT ... GROUP BY ... HAVING ...) AS count_table
+func (q *DBQuery) ApplyCount(db *gorm.DB) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	db = q.applyJoins(db)
+	if q.Select != "" {
+		db = db.Select(q.Select)
+	}
+	if q.CondExp != "" {
+		db = db.Where(q.CondExp, q.CondVal...)
+	}
+	if q.GroupBy != "" || q.HavingExp != "" || strings.HasPrefix(q.Select, "DISTINCT ") {
+		if q.GroupBy != "" {
+			db = db.Group(q.GroupBy)
+		}
+		if q.HavingExp != "" {
+			having := q.HavingExp
+			// the cursor value is not bound by the count query, and therefore, the having
+			// placeholders that are numbered after it are shifted back.
+			if q.dollar && q.Cursor != nil {
+				having = shiftPlaceholders(having, -1)
+			}
+			db = db.Having(having, q.HavingVal...)
+		}
+		return db.New().Raw("SELECT count(*) FROM (?) AS count_table", db.QueryExpr())
+	}
+	return db
+}
+
+// ListAndCount finds the rows of the query into out, and returns the total number of rows that
+// match the query, regardless of its limit and offset. out is a pointer to a slice of the model.
+// The count query is built with ApplyCount, and the find query with Apply. The queries run on
+// the given database instance, and therefore, they can be wrapped in a transaction by the caller.
+func ListAndCount(db *gorm.DB, q *DBQuery, out interface{}) (total int64, err error) {
+	if err := q.ApplyCount(db.Model(out)).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	if err := q.Apply(db).Find(out).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// applyJoins applies the joins of the query.
+func (q *DBQuery) applyJoins(db *gorm.DB) *gorm.DB {
+	for _, j := range q.Joins {
+		db = db.Joins(j)
+	}
+	return db
+}
+
+// applyOptions applies all query options except for the where statement of the filters.
+func (q *DBQuery) applyOptions(db *gorm.DB) *gorm.DB {
+	db = q.applyJoins(db)
 	if q.Offset != 0 {
 		db = db.Offset(q.Offset)
 	}
@@ -47,20 +234,255 @@ func (q *DBQuery) Apply(db *gorm.DB) *gorm.DB {
 	if q.Select != "" {
 		db = db.Select(q.Select)
 	}
-	if q.Sort != "" {
-		db = db.Order(q.Sort)
+	if q.GroupBy != "" {
+		db = db.Group(q.GroupBy)
 	}
-	if q.CondExp != "" {
-		db = db.Where(q.CondExp, q.CondVal...)
+	if q.HavingExp != "" {
+		db = db.Having(q.HavingExp, q.HavingVal...)
+	}
+	if q.Sort != "" && len(q.SortVal) > 0 {
+		db = db.Order(gorm.Expr(q.Sort, q.SortVal...))
+	} else if q.Sort != "" {
+		db = db.Order(q.Sort)
 	}
 	return db
 }
 
+// applyCursor applies the cursor condition of the query. it's applied after the where
+// statement, and therefore, its value is bound after the values of CondVal.
+func (q *DBQuery) applyCursor(db *gorm.DB) *gorm.DB {
+	if q.Cursor == nil {
+		return db
+	}
+	return db.Where(q.cursorExp(), q.Cursor.Value)
+}
+
+// cursorExp returns the condition of the cursor. i.e: "id > ?", or "id > $3" for
+// numbered placeholders.
+func (q *DBQuery) cursorExp() string {
+	op := " > "
+	if q.Cursor.Before {
+		op = " < "
+	}
+	if q.dollar {
+		return q.Cursor.Column + op + "$" + strconv.Itoa(len(q.CondVal)+1)
+	}
+	return q.Cursor.Column + op + "?"
+}
+
+// PrevCursor returns the value of the "before" param of the previous page, given the
+// first row of the current page (in its natural order). it returns an empty string if
+// the query has no cursor field, or if the row does not have it.
+func (q *DBQuery) PrevCursor(firstRow interface{}) string {
+	if q.cursorField == "" {
+		return ""
+	}
+	v := reflect.Indirect(reflect.ValueOf(firstRow))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	field := v.FieldByName(q.cursorField)
+	if !field.IsValid() {
+		return ""
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return ""
+		}
+		field = field.Elem()
+	}
+	if t, ok := field.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// NaturalOrder reverses the rows of a backward page (see Cursor.Before), so they are
+// ordered as the rows of a forward page. rows is a slice, or a pointer to a slice.
+func (q *DBQuery) NaturalOrder(rows interface{}) {
+	if q.Cursor == nil || !q.Cursor.Before {
+		return
+	}
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice {
+		return
+	}
+	swap := reflect.Swapper(v.Interface())
+	for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// cursorVals returns the number of values that are bound by the cursor condition.
+func (q *DBQuery) cursorVals() int {
+	if q.Cursor == nil {
+		return 0
+	}
+	return 1
+}
+
 // And adds expression to the current where statement with AND condition
 func (q *DBQuery) And(exp string, vals ...interface{}) {
+	if q.ored {
+		q.CondExp, q.ored = "("+q.CondExp+")", false
+	}
 	if q.CondExp != "" {
 		q.CondExp += " AND " // combine the two expressions together.
 	}
 	q.CondExp += exp
 	q.CondVal = append(q.CondVal, vals...)
+	q.Clauses = append(q.Clauses, Clause{Exp: exp, Vals: vals})
+}
+
+// Or adds expression to the current where statement with OR condition. the current
+// expression and the new one are parenthesized. i.e: "(age > ?) OR (is_public = true)".
+func (q *DBQuery) Or(exp string, vals ...interface{}) {
+	if q.CondExp == "" {
+		q.And(exp, vals...)
+		return
+	}
+	q.CondExp, q.ored = "("+q.CondExp+") OR ("+exp+")", true
+	q.CondVal = append(q.CondVal, vals...)
+	// ApplyStructured combines the clauses with AND, and therefore, they are merged into one.
+	q.Clauses = []Clause{{Exp: q.CondExp, Vals: append([]interface{}(nil), q.CondVal...)}}
+}
+
+// DebugString returns a human-readable representation of the query, with the arguments
+// interpolated into the expressions. for example:
+//
+//	SELECT * WHERE name = 'a8m' AND age IN (1, 2) ORDER BY age desc LIMIT 25 OFFSET 0
+//
+// It's intended for logs and tests only, and must never be executed against a database.
+func (q *DBQuery) DebugString() string {
+	if q == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if q.Select != "" {
+		b.WriteString(q.Select)
+	} else {
+		b.WriteString("*")
+	}
+	for _, j := range q.Joins {
+		b.WriteString(" " + j)
+	}
+	var where []string
+	if q.CondExp != "" {
+		where = append(where, interpolate(q.CondExp, q.CondVal, 0))
+	}
+	if q.Cursor != nil {
+		where = append(where, interpolate(q.cursorExp(), []interface{}{q.Cursor.Value}, len(q.CondVal)))
+	}
+	if len(where) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(where, " AND "))
+	}
+	if q.GroupBy != "" {
+		b.WriteString(" GROUP BY ")
+		b.WriteString(q.GroupBy)
+	}
+	if q.HavingExp != "" {
+		b.WriteString(" HAVING ")
+		b.WriteString(interpolate(q.HavingExp, q.HavingVal, len(q.CondVal)+q.cursorVals()))
+	}
+	if q.Sort != "" {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(interpolate(q.Sort, q.SortVal, len(q.CondVal)+q.cursorVals()+len(q.HavingVal)))
+	}
+	fmt.Fprintf(&b, " LIMIT %d OFFSET %d", q.Limit, q.Offset)
+	return b.String()
+}
+
+// interpolate replaces the placeholders in the expression with the quoted arguments.
+// "?" placeholders consume the arguments in order, and ordinal placeholders ("$n") are
+// the n-th argument after the given offset. placeholders without an argument are kept.
+func interpolate(exp string, vals []interface{}, offset int) string {
+	var b strings.Builder
+	for i, next := 0, 0; i < len(exp); i++ {
+		switch {
+		case exp[i] == '?' && next < len(vals):
+			b.WriteString(quote(vals[next]))
+			next++
+		case exp[i] == '$':
+			j := i + 1
+			for j < len(exp) && exp[j] >= '0' && exp[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(exp[i+1 : j])
+			if err != nil || n-1-offset < 0 || n-1-offset >= len(vals) {
+				b.WriteByte(exp[i])
+				continue
+			}
+			b.WriteString(quote(vals[n-1-offset]))
+			i = j - 1
+		default:
+			b.WriteByte(exp[i])
+		}
+	}
+	return b.String()
+}
+
+// shiftPlaceholders adds delta to the numbers of the ordinal placeholders of the expression.
+func shiftPlaceholders(exp string, delta int) string {
+	var b strings.Builder
+	for i := 0; i < len(exp); i++ {
+		j := i + 1
+		for exp[i] == '$' && j < len(exp) && exp[j] >= '0' && exp[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(exp[i])
+			continue
+		}
+		n, _ := strconv.Atoi(exp[i+1 : j])
+		b.WriteString("$" + strconv.Itoa(n+delta))
+		i = j - 1
+	}
+	return b.String()
+}
+
+// quote returns the SQL literal representation of the given value, for display only.
+func quote(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case []byte:
+		return quote(string(v))
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case time.Time:
+		return quote(v.Format(time.RFC3339Nano))
+	case *time.Time:
+		if v == nil {
+			return "NULL"
+		}
+		return quote(*v)
+	case time.Duration:
+		// durations are stored as nanoseconds.
+		return strconv.FormatInt(int64(v), 10)
+	case fmt.Stringer:
+		return quote(v.String())
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return quote(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		// slices are expanded by gorm to a comma-separated list. e.g. "IN (?)".
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = quote(rv.Index(i).Interface())
+		}
+		return strings.Join(items, ", ")
+	case reflect.String:
+		return quote(rv.String())
+	default:
+		return fmt.Sprint(v)
+	}
 }
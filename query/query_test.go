@@ -1,14 +1,25 @@
 package query
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-openapi/spec"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type MyEnum string
@@ -18,6 +29,10 @@ const (
 	enumVal2 MyEnum = "v2"
 )
 
+func (MyEnum) Enum() []interface{} {
+	return []interface{}{enumVal1, enumVal2}
+}
+
 type UserProperties map[string]string
 func (m UserProperties) Validate(s string) error {
 	return nil
@@ -188,6 +203,34 @@ func TestQuery(t *testing.T) {
 				CondVal: []interface{}{"%a8m%"},
 			},
 		},
+		{
+			name: "not like",
+			configInput: &Config{
+				Model: &model{},
+			},
+			parseInput: url.Values{
+				"name_not_like": []string{"a8m"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit:   25,
+				CondExp: "name NOT LIKE ?",
+				CondVal: []interface{}{"%a8m%"},
+			},
+		},
+		{
+			name: "not like delegation",
+			configInput: &Config{
+				Model: &model{},
+			},
+			parseInput: url.Values{
+				"tag_name_not_like": []string{"a8m"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit:   25,
+				CondExp: "(name IN (SELECT DISTINCT tag_name IN tags WHERE tag_name NOT LIKE ?))",
+				CondVal: []interface{}{"%a8m%"},
+			},
+		},
 		{
 			name: "expression delegation",
 			configInput: &Config{
@@ -203,6 +246,64 @@ func TestQuery(t *testing.T) {
 				CondVal: []interface{}{"a", "b"},
 			},
 		},
+		{
+			name: "null-safe neq on nullable fields",
+			configInput: &Config{
+				NullSafeNeq: true,
+			},
+			parseInput: url.Values{
+				"flag_ptr_neq":     []string{"true"},
+				"enum_val_ptr_neq": []string{"v1"},
+				"flag_neq":         []string{"true"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit:   25,
+				CondExp: "(flag_ptr <> ? OR flag_ptr IS NULL) AND (enum_val_ptr <> ? OR enum_val_ptr IS NULL) AND flag <> ?",
+				CondVal: []interface{}{"true", "v1", "true"},
+			},
+		},
+		{
+			name:        "neq on nullable fields without null-safe option",
+			configInput: &Config{},
+			parseInput: url.Values{
+				"flag_ptr_neq": []string{"true"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit:   25,
+				CondExp: "flag_ptr <> ?",
+				CondVal: []interface{}{"true"},
+			},
+		},
+		{
+			name: "case-insensitive params",
+			configInput: &Config{
+				CaseInsensitiveParams: true,
+			},
+			parseInput: url.Values{
+				"Limit":   []string{"10"},
+				"OFFSET":  []string{"5"},
+				"AGE_GT":  []string{"10"},
+				"Name_Eq": []string{"a8m"},
+				"name_eq": []string{"pos"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit:   10,
+				Offset:  5,
+				CondExp: "age > ? AND (name = ? OR name = ?)",
+				CondVal: []interface{}{int64(10), "a8m", "pos"},
+			},
+		},
+		{
+			name:        "case-sensitive params by default",
+			configInput: &Config{},
+			parseInput: url.Values{
+				"Limit":  []string{"10"},
+				"AGE_GT": []string{"10"},
+			},
+			expectedQueryInput: &DBQuery{
+				Limit: 25,
+			},
+		},
 		{
 			name: "one search term",
 			configInput: &Config{
@@ -342,3 +443,3682 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestUnknownParams(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	params := url.Values{
+		"limit":     []string{"10"},
+		"sort":      []string{"name"},
+		"search":    []string{"foo"},
+		"name_like": []string{"a8m"},
+		"tag_name":  []string{"foo"},
+		"nam":       []string{"foo"},
+		"age_like":  []string{"10"},
+		"debug":     []string{"true"},
+	}
+	assert.Equal(t, []string{"age_like", "debug", "nam"}, b.UnknownParams(params))
+	assert.Empty(t, b.UnknownParams(url.Values{"offset": []string{"1"}, "age_gte": []string{"1"}}))
+}
+
+func TestParseWithColumnRewriter(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:          model{},
+		DefaultSort:    "name desc, created_at",
+		ExplicitSelect: true,
+	})
+	rewrite := func(col string) string { return "tenant_x.pets." + col }
+
+	q, err := b.ParseWithColumnRewriter(url.Values{}, rewrite)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant_x.pets.name desc, tenant_x.pets.created_at", q.Sort)
+	for _, col := range strings.Split(q.Select, ",") {
+		assert.True(t, strings.HasPrefix(col, "tenant_x.pets."), "select column: %s", col)
+	}
+
+	q, err = b.ParseWithColumnRewriter(url.Values{
+		"sort":         []string{"-updated_at", "name"},
+		"age_gt":       []string{"10"},
+		"name_like":    []string{"a8m"},
+		"tag_name":     []string{"foo"},
+		"flag_ptr_neq": []string{"true"},
+	}, rewrite)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant_x.pets.updated_at desc, tenant_x.pets.name", q.Sort)
+	actual := strings.Split(q.CondExp, " AND ")
+	sort.Strings(actual)
+	assert.Equal(t, []string{
+		"(name IN (SELECT DISTINCT tag_name IN tags WHERE tenant_x.pets.tag_name = ?))",
+		"tenant_x.pets.age > ?",
+		"tenant_x.pets.flag_ptr <> ?",
+		"tenant_x.pets.name LIKE ?",
+	}, actual)
+
+	// Parse does not rewrite the columns.
+	q, err = b.Parse(url.Values{"age_gt": []string{"10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "age > ?", q.CondExp)
+	assert.Equal(t, "name desc, created_at", q.Sort)
+}
+
+func TestPagination(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, DefaultLimit: 20})
+
+	q, err := b.Parse(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, Pagination{Limit: 20, Offset: 0, DefaultLimit: true, DefaultOffset: true}, q.Pagination)
+
+	q, err = b.Parse(url.Values{"offset": []string{"40"}})
+	assert.NoError(t, err)
+	assert.Equal(t, Pagination{Limit: 20, Offset: 40, DefaultLimit: true}, q.Pagination)
+
+	q, err = b.Parse(url.Values{"limit": []string{"5"}, "offset": []string{"10"}})
+	assert.NoError(t, err)
+	assert.Equal(t, Pagination{Limit: 5, Offset: 10}, q.Pagination)
+}
+
+func TestClampLimit(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, LimitMaxValue: 50, ClampLimit: true})
+
+	q, err := b.Parse(url.Values{"limit": []string{"200"}})
+	require.NoError(t, err)
+	assert.True(t, q.LimitClamped)
+	assert.Equal(t, 50, q.Limit)
+	assert.Equal(t, 50, q.Pagination.Limit)
+
+	q, err = b.Parse(url.Values{"limit": []string{"50"}})
+	require.NoError(t, err)
+	assert.False(t, q.LimitClamped)
+	assert.Equal(t, 50, q.Limit)
+
+	for _, limit := range []string{"-1", "ten"} {
+		_, err = b.Parse(url.Values{"limit": []string{limit}})
+		assert.IsType(t, &ParseError{}, err, "invalid values are still rejected")
+	}
+
+	// without the option, exceeding the maximum fails.
+	b = MustNewBuilder(&Config{Model: model{}, LimitMaxValue: 50})
+	_, err = b.Parse(url.Values{"limit": []string{"200"}})
+	assert.EqualError(t, err, "value for key 'limit' must be less than or equal to 50")
+	_, err = b.Parse(url.Values{"limit": []string{"-1"}})
+	assert.EqualError(t, err, "value for key 'limit' must be greater than or equal to 0")
+}
+
+func TestRedact(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, CaseInsensitiveParams: true})
+	params := url.Values{
+		"Name_Like": []string{"a8m"},
+		"age_in":    []string{"1,2", "3"},
+		"search":    []string{"secret"},
+		"limit":     []string{"10"},
+		"offset":    []string{"20"},
+		"sort":      []string{"-name", "age"},
+		"unknown":   []string{"value"},
+	}
+	redacted := b.Redact(params)
+	assert.Equal(t, url.Values{
+		"name_like": []string{"REDACTED"},
+		"age_in":    []string{"REDACTED", "REDACTED"},
+		"search":    []string{"REDACTED"},
+		"limit":     []string{"10"},
+		"offset":    []string{"20"},
+		"sort":      []string{"-name", "age"},
+		"unknown":   []string{"REDACTED"},
+	}, redacted)
+	assert.Equal(t, []string{"a8m"}, params["Name_Like"], "input params should not be modified")
+}
+
+func TestStrictParams(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, StrictParams: true, ReservedParams: []string{"expand"}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr string
+	}{
+		{
+			name:   "known params",
+			params: url.Values{"name_like": []string{"a8m"}, "age_gte": []string{"1"}, "limit": []string{"1"}, "offset": []string{"1"}, "sort": []string{"name"}, "search": []string{"a"}},
+		},
+		{
+			name:   "reserved params",
+			params: url.Values{"expand": []string{"owner"}},
+		},
+		{
+			name:    "typo",
+			params:  url.Values{"nam": []string{"foo"}},
+			wantErr: "unknown parameter 'nam'",
+		},
+		{
+			name:    "unknown operator",
+			params:  url.Values{"name_gte": []string{"foo"}, "age_foo": []string{"1"}},
+			wantErr: "unknown parameter 'age_foo'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.Parse(tt.params)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.IsType(t, &ParseError{}, err)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+	// unknown params are ignored by default.
+	_, err := MustNewBuilder(&Config{Model: model{}}).Parse(url.Values{"nam": []string{"foo"}})
+	assert.NoError(t, err)
+}
+
+func TestCursorPagination(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}, CursorField: "age"})
+
+	q, err := b.Parse(url.Values{"after": []string{"10"}, "offset": []string{"20"}, "name": []string{"a8m"}, "limit": []string{"5"}})
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{Column: "age", Value: 10}, q.Cursor)
+	assert.Equal(t, 0, q.Offset, "offset is ignored with a cursor")
+	assert.Equal(t, "age asc", q.Sort)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name = $1) AND (age > $2) ORDER BY age asc LIMIT 5`, captureSQL(t, q.Apply, "a8m", 10))
+	assert.Equal(t, "SELECT * WHERE name = 'a8m' AND age > 10 ORDER BY age asc LIMIT 5 OFFSET 0", q.DebugString())
+
+	q, err = b.ParseWithColumnRewriter(url.Values{"after": []string{"10"}}, func(c string) string { return "pets." + c })
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{Column: "pets.age", Value: 10}, q.Cursor)
+
+	// without a cursor, the offset pagination is used.
+	q, err = b.Parse(url.Values{"offset": []string{"20"}})
+	require.NoError(t, err)
+	assert.Nil(t, q.Cursor)
+	assert.Equal(t, 20, q.Offset)
+
+	_, err = b.Parse(url.Values{"after": []string{"ten"}})
+	assert.IsType(t, &ParseError{}, err, "the cursor value is validated by the field type")
+	_, err = b.Parse(url.Values{"after": []string{"10"}, "sort": []string{"name"}})
+	assert.IsType(t, &ParseError{}, err)
+	assert.Empty(t, b.UnknownParams(url.Values{"after": []string{"10"}}))
+
+	_, err = NewBuilder(&Config{Model: pet{}, CursorField: "id"})
+	assert.Error(t, err, "cursor field must be a filter field")
+}
+
+func TestBeforeCursor(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}, CursorField: "age"})
+	rows := []pet{{Name: "a", Age: 1}, {Name: "b", Age: 2}, {Name: "c", Age: 3}, {Name: "d", Age: 4}, {Name: "e", Age: 5}}
+
+	// forward paging.
+	q, err := b.Parse(url.Values{"after": []string{"2"}, "limit": []string{"2"}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age > $1) ORDER BY age asc LIMIT 2`, captureSQL(t, q.Apply, 2))
+	page := []pet{rows[2], rows[3]}
+	q.NaturalOrder(page)
+	assert.Equal(t, []pet{rows[2], rows[3]}, page, "forward pages are in natural order")
+	assert.Equal(t, "3", q.PrevCursor(page[0]))
+
+	// backward paging returns the page before the first row, in the same order.
+	q, err = b.Parse(url.Values{"before": []string{q.PrevCursor(&page[0])}, "name": []string{"a"}, "limit": []string{"2"}})
+	require.NoError(t, err)
+	assert.Equal(t, &Cursor{Column: "age", Value: 3, Before: true}, q.Cursor)
+	assert.Equal(t, []SortField{{Column: "age", Desc: true}}, q.SortFields)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name = $1) AND (age < $2) ORDER BY age desc LIMIT 2`, captureSQL(t, q.Apply, "a", 3))
+	assert.Equal(t, "SELECT * WHERE name = 'a' AND age < 3 ORDER BY age desc LIMIT 2 OFFSET 0", q.DebugString())
+	page = []pet{rows[1], rows[0]}
+	q.NaturalOrder(&page)
+	assert.Equal(t, []pet{rows[0], rows[1]}, page)
+	assert.Equal(t, "1", q.PrevCursor(page[0]))
+
+	// numbered placeholders.
+	b = MustNewBuilder(&Config{Model: pet{}, CursorField: "age", Placeholder: PlaceholderDollar})
+	q, err = b.Parse(url.Values{"before": []string{"3"}, "name": []string{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, "age < $2", q.cursorExp())
+
+	_, err = b.Parse(url.Values{"before": []string{"3"}, "after": []string{"1"}})
+	assert.IsType(t, &ParseError{}, err)
+	_, err = b.Parse(url.Values{"before": []string{"3"}, "sort": []string{"name"}})
+	assert.IsType(t, &ParseError{}, err)
+	_, err = b.Parse(url.Values{"before": []string{"three"}})
+	assert.IsType(t, &ParseError{}, err)
+	assert.Empty(t, b.UnknownParams(url.Values{"before": []string{"3"}}))
+
+	// without a cursor field, there is no previous cursor.
+	q, err = MustNewBuilder(&Config{Model: pet{}}).Parse(url.Values{})
+	require.NoError(t, err)
+	assert.Empty(t, q.PrevCursor(rows[0]))
+}
+
+type searchCollision struct {
+	Name string `query:"filter"`
+	Text string `query:"filter,param=search"`
+}
+
+func (searchCollision) Search(string) (string, []interface{}) { return "", nil }
+
+func TestSearchParamCollision(t *testing.T) {
+	_, err := NewBuilder(&Config{Model: searchCollision{}})
+	assert.EqualError(t, err, "query: filter 'search' collides with the search param of the model")
+
+	_, err = NewBuilder(&Config{Model: model{}, ComputedFilters: map[string]string{"search": "name || status"}})
+	assert.Error(t, err)
+
+	// models without search can use the name for a filter.
+	b, err := NewBuilder(&Config{Model: struct {
+		Text string `query:"filter,param=search"`
+	}{}})
+	require.NoError(t, err)
+	q, err := b.Parse(url.Values{"search": []string{"foo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "search = ?", q.CondExp)
+}
+
+func TestSearchParam(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, SearchParam: "q"})
+	q, err := b.Parse(url.Values{"q": []string{"foo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "(name = ? OR status LIKE ?)", q.CondExp)
+	assert.Equal(t, []interface{}{"foo", "%foo%"}, q.CondVal)
+	assert.Equal(t, []string{"search"}, b.UnknownParams(url.Values{"q": []string{"foo"}, "search": []string{"foo"}}))
+
+	// the configured name is checked for collisions with the filters.
+	_, err = NewBuilder(&Config{Model: searchCollision{}, SearchParam: "name"})
+	assert.EqualError(t, err, "query: filter 'name' collides with the search param of the model")
+	_, err = NewBuilder(&Config{Model: searchCollision{}, SearchParam: "q"})
+	assert.NoError(t, err)
+}
+
+type rankedSearch struct {
+	Name string `query:"filter"`
+}
+
+func (rankedSearch) Search(term string) (string, []interface{}) {
+	return "name = ?", []interface{}{term}
+}
+
+func (rankedSearch) SearchAll(terms []string) (string, []interface{}) {
+	return "tsv @@ to_tsquery(?)", []interface{}{strings.Join(terms, " & ")}
+}
+
+type multiSearch struct {
+	Name string `query:"filter"`
+}
+
+func (multiSearch) SearchAll(terms []string) (string, []interface{}) {
+	return "name IN (?)", []interface{}{terms}
+}
+
+func TestMultiSearcher(t *testing.T) {
+	params := url.Values{"search": []string{"foo", "bar"}}
+	q, err := MustNewBuilder(&Config{Model: rankedSearch{}}).Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, "tsv @@ to_tsquery(?)", q.CondExp, "SearchAll is preferred over Search")
+	assert.Equal(t, []interface{}{"foo & bar"}, q.CondVal)
+
+	b := MustNewBuilder(&Config{Model: multiSearch{}, SearchOperator: "OR"})
+	q, err = b.Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, "name IN (?)", q.CondExp)
+	assert.Equal(t, []interface{}{[]string{"foo", "bar"}}, q.CondVal)
+	assert.Empty(t, b.UnknownParams(params))
+}
+
+func TestDoubleDecodeValues(t *testing.T) {
+	params, err := url.ParseQuery("name=a8m%2520pos&age_in=1%252C2&status=100%25")
+	require.NoError(t, err)
+
+	q, err := MustNewBuilder(&Config{Model: model{}, DoubleDecodeValues: true}).Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, "age IN (?) AND name = ? AND status = ?", q.CondExp)
+	assert.Equal(t, []interface{}{[]int64{1, 2}, "a8m pos", "100%"}, q.CondVal)
+	assert.Equal(t, []string{"a8m%20pos"}, params["name"], "input params should not be modified")
+
+	// disabled by default.
+	q, err = MustNewBuilder(&Config{Model: model{}}).Parse(url.Values{"name": params["name"]})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a8m%20pos"}, q.CondVal)
+}
+
+func TestColumnUsageHook(t *testing.T) {
+	var got []string
+	b := MustNewBuilder(&Config{
+		Model:              pet{},
+		DefaultSort:        "name",
+		MultiColumnFilters: map[string][]ColumnFilter{"q": {{Column: "name", Op: opLike}}},
+		ComputedSorts:      map[string]ComputedSort{"score": {Exp: "age * 2"}},
+		ColumnUsageHook:    func(columns []string) { got = columns },
+	})
+	_, err := b.Parse(url.Values{"age_gt": []string{"1"}, "name": []string{"a8m"}, "q": []string{"x"}, "sort": []string{"-age", "score"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "name"}, got)
+
+	_, err = b.Parse(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name"}, got, "default sort")
+
+	got = nil
+	_, err = b.Parse(url.Values{"age_gt": []string{"x"}})
+	require.Error(t, err)
+	assert.Nil(t, got, "not called for invalid queries")
+}
+
+func TestParseWith(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:              pet{},
+		MultiColumnFilters: map[string][]ColumnFilter{"q": {{Column: "name", Op: opLike}}},
+	})
+	allowed := []string{"name", "q"}
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr bool
+	}{
+		{name: "allowed filter", params: url.Values{"name": []string{"a8m"}}},
+		{name: "allowed operator", params: url.Values{"name_neq": []string{"a8m"}}},
+		{name: "allowed multi-column filter", params: url.Values{"q": []string{"a8m"}}},
+		{name: "allowed sort", params: url.Values{"sort": []string{"-name"}}},
+		{name: "disallowed filter", params: url.Values{"age_gt": []string{"1"}}, wantErr: true},
+		{name: "disallowed sort", params: url.Values{"sort": []string{"+age"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.ParseWith(tt.params, allowed)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+	// the builder itself is not restricted.
+	_, err := b.Parse(url.Values{"age_gt": []string{"1"}, "sort": []string{"age"}})
+	assert.NoError(t, err)
+}
+
+func TestParseErrorFields(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}, StrictParams: true})
+	tests := []struct {
+		params    url.Values
+		wantParam string
+		wantCode  string
+		wantMsg   string
+	}{
+		{
+			params:    url.Values{"age_gt": []string{"x"}},
+			wantParam: "age_gt",
+			wantCode:  CodeInvalidValue,
+			wantMsg:   "invalid parameter for key 'age_gt'",
+		},
+		{
+			params:    url.Values{"unknown": []string{"1"}},
+			wantParam: "unknown",
+			wantCode:  CodeUnknownField,
+			wantMsg:   "unknown parameter 'unknown'",
+		},
+		{
+			params:    url.Values{"limit": []string{"1000"}},
+			wantParam: "limit",
+			wantCode:  CodeOutOfRange,
+			wantMsg:   "value for key 'limit' must be less than or equal to 100",
+		},
+		{
+			params:    url.Values{"sort": []string{"color"}},
+			wantParam: "sort",
+			wantCode:  CodeUnknownField,
+			wantMsg:   "invalid sort parameter 'color'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantMsg, func(t *testing.T) {
+			_, err := b.Parse(tt.params)
+			require.IsType(t, &ParseError{}, err)
+			perr := err.(*ParseError)
+			assert.Equal(t, tt.wantParam, perr.Param)
+			assert.Equal(t, tt.wantCode, perr.Code)
+			assert.Equal(t, tt.wantMsg, perr.Error())
+		})
+	}
+	_, err := b.ParseWith(url.Values{"age": []string{"1"}}, []string{"name"})
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, CodeNotAllowed, err.(*ParseError).Code)
+}
+
+func TestTypedInValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    interface{}
+		params   url.Values
+		wantType reflect.Type
+	}{
+		{
+			name:     "int",
+			model:    pet{},
+			params:   url.Values{"age_in": []string{"1,2"}},
+			wantType: reflect.TypeOf([]int{}),
+		},
+		{
+			name:     "string",
+			model:    pet{},
+			params:   url.Values{"name_not_in": []string{"a,b"}},
+			wantType: reflect.TypeOf([]string{}),
+		},
+		{
+			name:     "int64",
+			model:    model{},
+			params:   url.Values{"age_in": []string{"1,2"}},
+			wantType: reflect.TypeOf([]int64{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(&Config{Model: tt.model}).Parse(tt.params)
+			require.NoError(t, err)
+			require.Len(t, q.CondVal, 1)
+			assert.Equal(t, tt.wantType, reflect.TypeOf(q.CondVal[0]))
+		})
+	}
+}
+
+func TestExampleQuery(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:              model{},
+		MultiColumnFilters: map[string][]ColumnFilter{"q": {{Column: "name", Op: opLike}, {Column: "status", Op: opLike}}},
+		ComputedFilters:    map[string]string{"full_name": "name || status"},
+	})
+	example := b.ExampleQuery()
+	assert.Equal(t, url.Values{
+		"age_gte":           []string{"1"},
+		"created_at_gte":    []string{"2020-01-01T00:00:00Z"},
+		"enum_val_like":     []string{"example"},
+		"enum_val_ptr_like": []string{"example"},
+		"flag":              []string{"true"},
+		"flag_ptr":          []string{"true"},
+		"full_name_like":    []string{"example"},
+		"name_like":         []string{"example"},
+		"q":                 []string{"example"},
+		"status_like":       []string{"example"},
+		"tag_name":          []string{"1"},
+		"updated_at_gte":    []string{"2020-01-01T00:00:00Z"},
+		"year_gte":          []string{"1"},
+	}, example)
+	_, err := b.Parse(example)
+	assert.NoError(t, err)
+}
+
+func TestTimeFormats(t *testing.T) {
+	type event struct {
+		At      time.Time  `query:"filter"`
+		EndedAt *time.Time `query:"filter"`
+	}
+	day := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	next := day.AddDate(0, 0, 1)
+	b := MustNewBuilder(&Config{Model: event{}, TimeFormats: []string{time.RFC3339, "2006-01-02"}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "rfc3339",
+			params:  url.Values{"at": []string{"2020-01-02T00:00:00Z"}},
+			wantExp: "at = ?",
+			wantVal: []interface{}{day},
+		},
+		{
+			name:    "date only",
+			params:  url.Values{"at_gte": []string{"2020-01-02"}},
+			wantExp: "at >= ?",
+			wantVal: []interface{}{day},
+		},
+		{
+			name:    "unix seconds",
+			params:  url.Values{"at_lt": []string{"1577923200"}},
+			wantExp: "at < ?",
+			wantVal: []interface{}{day},
+		},
+		{
+			name:    "between",
+			params:  url.Values{"at_between": []string{"2020-01-02,1578009600"}},
+			wantExp: "at BETWEEN ? AND ?",
+			wantVal: []interface{}{day, next},
+		},
+		{
+			name:    "invalid",
+			params:  url.Values{"at": []string{"02/01/2020"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// pointer fields use the same layouts.
+	q, err := b.Parse(url.Values{"ended_at": []string{"2020-01-02"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ended_at = ?", q.CondExp)
+
+	// the date only layout is not accepted by default.
+	_, err = MustNewBuilder(&Config{Model: event{}}).Parse(url.Values{"at": []string{"2020-01-02"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestLocation(t *testing.T) {
+	params := url.Values{"created_at": []string{"2020-01-02T03:00:00+03:00"}, "name": []string{"a8m"}}
+	q, err := MustNewBuilder(&Config{Model: model{}, Location: time.UTC}).Parse(params)
+	require.NoError(t, err)
+	require.Len(t, q.CondVal, 2)
+	assert.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), q.CondVal[0])
+	assert.Equal(t, time.UTC, q.CondVal[0].(time.Time).Location())
+	assert.Equal(t, "a8m", q.CondVal[1])
+
+	// the offset of the input is kept by default.
+	q, err = MustNewBuilder(&Config{Model: model{}}).Parse(params)
+	require.NoError(t, err)
+	_, offset := q.CondVal[0].(time.Time).Zone()
+	assert.Equal(t, 3*60*60, offset)
+}
+
+func TestOrGroup(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, OrParam: "or[]", StrictParams: true})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "group",
+			params:  url.Values{"or[]": []string{"name_eq:a", "status:open"}},
+			wantExp: "(name = ? OR status = ?)",
+			wantVal: []interface{}{"a", "open"},
+		},
+		{
+			name:    "group and filters",
+			params:  url.Values{"age_gt": []string{"10"}, "or[]": []string{"name_like:a8m", "age_in:1,2"}},
+			wantExp: "age > ? AND (name LIKE ? OR age IN (?))",
+			wantVal: []interface{}{int64(10), "%a8m%", []int64{1, 2}},
+		},
+		{
+			name:    "invalid value",
+			params:  url.Values{"or[]": []string{"age:x"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown filter",
+			params:  url.Values{"or[]": []string{"unknown:x"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing separator",
+			params:  url.Values{"or[]": []string{"name"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// the number of terms is bounded by MaxOrGroups.
+	limited := MustNewBuilder(&Config{Model: model{}, OrParam: "or[]", MaxOrGroups: 2})
+	_, err := limited.Parse(url.Values{"or[]": []string{"name:a", "age:1"}})
+	require.NoError(t, err)
+	_, err = limited.Parse(url.Values{"or[]": []string{"name:a", "age:1", "status:open"}})
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, CodeNotAllowed, err.(*ParseError).Code)
+	assert.Equal(t, "or[]", err.(*ParseError).Param)
+
+	// the terms are checked against the allow-list of ParseWith.
+	_, err = b.ParseWith(url.Values{"or[]": []string{"name:a", "age:1"}}, []string{"name"})
+	assert.IsType(t, &ParseError{}, err)
+
+	// the grouping is disabled by default.
+	_, err = MustNewBuilder(&Config{Model: model{}, StrictParams: true}).Parse(url.Values{"or[]": []string{"name:a"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestSQLNullFields(t *testing.T) {
+	type row struct {
+		Name    sql.NullString  `query:"filter"`
+		Count   sql.NullInt64   `query:"filter"`
+		Rank    *sql.NullInt32  `query:"filter"`
+		Score   sql.NullFloat64 `query:"filter"`
+		Active  sql.NullBool    `query:"filter"`
+		EndedAt sql.NullTime    `query:"filter"`
+	}
+	at := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	b := MustNewBuilder(&Config{Model: row{}, NullSafeNeq: true})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{name: "string", params: url.Values{"name_like": []string{"a8m"}}, wantExp: "name LIKE ?", wantVal: []interface{}{"%a8m%"}},
+		{name: "int64", params: url.Values{"count_gt": []string{"1"}}, wantExp: "count > ?", wantVal: []interface{}{int64(1)}},
+		{name: "int32 pointer", params: url.Values{"rank": []string{"2"}}, wantExp: "rank = ?", wantVal: []interface{}{int32(2)}},
+		{name: "float64", params: url.Values{"score_lte": []string{"1.5"}}, wantExp: "score <= ?", wantVal: []interface{}{1.5}},
+		{name: "bool", params: url.Values{"active": []string{"true"}}, wantExp: "active = ?", wantVal: []interface{}{"true"}},
+		{name: "time", params: url.Values{"ended_at_gte": []string{"2020-01-02T00:00:00Z"}}, wantExp: "ended_at >= ?", wantVal: []interface{}{at}},
+		{name: "null", params: url.Values{"ended_at_null": []string{"true"}}, wantExp: "ended_at IS NULL"},
+		{name: "null safe neq", params: url.Values{"name_neq": []string{"a8m"}}, wantExp: "(name <> ? OR name IS NULL)", wantVal: []interface{}{"a8m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	_, err := b.Parse(url.Values{"rank": []string{"3000000000"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+// ptrTags implements the Wrapper interface with a pointer receiver.
+type ptrTags []string
+
+func (*ptrTags) Wrap(s string) string { return "(id IN (SELECT pet_id FROM tags WHERE " + s + "))" }
+
+func TestPointerWrappers(t *testing.T) {
+	type tagged struct {
+		Tags    *Tags    `query:"filter"`
+		Labels  ptrTags  `query:"filter"`
+		Markers *ptrTags `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: tagged{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+	}{
+		{
+			name:    "pointer field, value receiver",
+			params:  url.Values{"tags": []string{"a"}},
+			wantExp: "(name IN (SELECT DISTINCT tag_name IN tags WHERE tags = ?))",
+		},
+		{
+			name:    "value field, pointer receiver",
+			params:  url.Values{"labels": []string{"a"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE labels = ?))",
+		},
+		{
+			name:    "pointer field, pointer receiver",
+			params:  url.Values{"markers_like": []string{"a"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE markers LIKE ?))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+		})
+	}
+}
+
+func TestHavingFields(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:        pet{},
+		HavingFields: map[string]string{"pet_count": "COUNT(*)", "max_age": "MAX(age)"},
+		Placeholder:  PlaceholderDollar,
+		DefaultSort:  "name",
+	})
+	q, err := b.Parse(url.Values{"name": []string{"a8m"}, "pet_count_gt": []string{"2"}, "max_age_lte": []string{"10"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name = $1", q.CondExp)
+	assert.Equal(t, "MAX(age) <= $2 AND COUNT(*) > $3", q.HavingExp)
+	assert.Equal(t, []interface{}{10.0, 2.0}, q.HavingVal)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name = $1) HAVING (MAX(age) <= $2 AND COUNT(*) > $3) ORDER BY "name" LIMIT 25`, captureSQL(t, q.Apply, "a8m", 10.0, 2.0))
+	assert.Equal(t, "SELECT * WHERE name = 'a8m' HAVING MAX(age) <= 10 AND COUNT(*) > 2 ORDER BY name LIMIT 25 OFFSET 0", q.DebugString())
+
+	// the having clause is skipped when there are no having params.
+	q, err = b.Parse(url.Values{"name": []string{"a8m"}})
+	require.NoError(t, err)
+	assert.Empty(t, q.HavingExp)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name = $1) ORDER BY "name" LIMIT 25`, captureSQL(t, q.Apply, "a8m"))
+
+	_, err = b.Parse(url.Values{"pet_count_gt": []string{"x"}})
+	assert.IsType(t, &ParseError{}, err)
+
+	_, err = NewBuilder(&Config{Model: pet{}, HavingFields: map[string]string{"age": "COUNT(*)"}})
+	assert.EqualError(t, err, "query: having field 'age' collides with a filter")
+}
+
+func TestGroupBy(t *testing.T) {
+	type groupedPet struct {
+		ID      int
+		Name    string `query:"filter"`
+		OwnerID int    `query:"filter,group"`
+		Color   string `query:"group"`
+	}
+	b := MustNewBuilder(&Config{
+		Model:          groupedPet{},
+		ExplicitSelect: true,
+		HavingFields:   map[string]string{"pet_count": "COUNT(*)"},
+	})
+	q, err := b.Parse(url.Values{"group": []string{"owner_id", "color"}, "pet_count_gt": []string{"1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "owner_id, color", q.GroupBy)
+	assert.Equal(t, "owner_id, color", q.Select, "select the group columns")
+	assert.Equal(t, "SELECT owner_id, color GROUP BY owner_id, color HAVING COUNT(*) > 1 LIMIT 25 OFFSET 0", q.DebugString())
+
+	q, err = b.Parse(url.Values{})
+	require.NoError(t, err)
+	assert.Empty(t, q.GroupBy)
+	assert.Equal(t, "id,name,owner_id,color", q.Select)
+
+	_, err = b.Parse(url.Values{"group": []string{"name"}})
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, "invalid group parameter 'name'", err.Error())
+
+	b = MustNewBuilder(&Config{Model: pet{}, GroupBy: "name"})
+	q, err = b.Parse(url.Values{"age_gt": []string{"1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name", q.GroupBy)
+	assert.Equal(t, `SELECT name FROM "pets"  WHERE (age > $1) GROUP BY name LIMIT 25`, captureSQL(t, q.Apply, 1))
+}
+
+func TestAllowDistinct(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *Config
+		params     url.Values
+		wantSelect string
+		wantErr    bool
+	}{
+		{
+			name:       "select all",
+			config:     &Config{Model: pet{}, AllowDistinct: true},
+			params:     url.Values{"distinct": []string{"true"}},
+			wantSelect: "DISTINCT *",
+		},
+		{
+			name:       "explicit select",
+			config:     &Config{Model: pet{}, AllowDistinct: true, ExplicitSelect: true},
+			params:     url.Values{"distinct": []string{"true"}},
+			wantSelect: "DISTINCT id,name,age",
+		},
+		{
+			name:       "false",
+			config:     &Config{Model: pet{}, AllowDistinct: true, ExplicitSelect: true},
+			params:     url.Values{"distinct": []string{"false"}},
+			wantSelect: "id,name,age",
+		},
+		{
+			name:    "invalid",
+			config:  &Config{Model: pet{}, AllowDistinct: true},
+			params:  url.Values{"distinct": []string{"x"}},
+			wantErr: true,
+		},
+		{
+			name:   "disabled",
+			config: &Config{Model: pet{}},
+			params: url.Values{"distinct": []string{"true"}},
+		},
+		{
+			name:    "disabled strict",
+			config:  &Config{Model: pet{}, StrictParams: true},
+			params:  url.Values{"distinct": []string{"true"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSelect, q.Select)
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	type item struct {
+		Name   string `query:"filter,sort"`
+		Count  int    `query:"filter"`
+		Notes  string `query:"detailed"`
+		Hidden bool
+	}
+	b := MustNewBuilder(&Config{
+		Model:                       item{},
+		OnlySelectNonDetailedFields: true,
+		DisabledOperators:           []string{opNull},
+		ComputedFilters:             map[string]string{"label": "name || notes"},
+	})
+	assert.Equal(t, []FieldInfo{
+		{Name: "count", Column: "count", Operators: []string{"between", "eq", "gt", "gte", "in", "lt", "lte", "neq", "not_in"}, Selected: true},
+		{Name: "hidden", Column: "hidden", Selected: true},
+		{
+			Name:      "label",
+			Column:    "(name || notes)",
+			Operators: []string{"ends_with", "eq", "ieq", "ilike", "in", "ineq", "like", "neq", "not_in", "not_like", "starts_with"},
+		},
+		{
+			Name:      "name",
+			Column:    "name",
+			Operators: []string{"ends_with", "eq", "ieq", "ilike", "in", "ineq", "like", "neq", "not_in", "not_like", "starts_with"},
+			Sortable:  true,
+			Selected:  true,
+		},
+		{Name: "notes", Column: "notes", Detailed: true},
+	}, b.Fields())
+}
+
+func TestJSONFields(t *testing.T) {
+	type doc struct {
+		Name  string            `query:"filter"`
+		Meta  map[string]string `query:"filter,json"`
+		Attrs json.RawMessage   `query:"filter,json"`
+	}
+	b := MustNewBuilder(&Config{Model: doc{}, Dialect: DialectPostgres, StrictParams: true})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "eq",
+			params:  url.Values{"meta.color_eq": []string{"red"}},
+			wantExp: "meta->>'color' = ?",
+			wantVal: []interface{}{"red"},
+		},
+		{
+			name:    "bare key with separator",
+			params:  url.Values{"attrs.first_name": []string{"a8m"}},
+			wantExp: "attrs->>'first_name' = ?",
+			wantVal: []interface{}{"a8m"},
+		},
+		{
+			name:    "string operators",
+			params:  url.Values{"meta.color_not_in": []string{"red,blue"}, "meta.size_like": []string{"x"}, "name": []string{"a"}},
+			wantExp: "name = ? AND meta->>'color' NOT IN (?) AND meta->>'size' LIKE ?",
+			wantVal: []interface{}{"a", []string{"red", "blue"}, "%x%"},
+		},
+		{
+			name:    "invalid key",
+			params:  url.Values{"meta.col'or": []string{"red"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			params:  url.Values{"other.color": []string{"red"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// the fields are checked against the allow-list of ParseWith.
+	_, err := b.ParseWith(url.Values{"meta.color": []string{"red"}}, []string{"meta"})
+	assert.NoError(t, err)
+	_, err = b.ParseWith(url.Values{"meta.color": []string{"red"}}, []string{"name"})
+	assert.IsType(t, &ParseError{}, err)
+
+	// JSON fields are supported only on Postgres.
+	b = MustNewBuilder(&Config{Model: doc{}, Dialect: DialectMySQL})
+	assert.Equal(t, []string{"meta.color"}, b.UnknownParams(url.Values{"meta.color": []string{"red"}}))
+}
+
+func TestOffsetMaxValue(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, OffsetMaxValue: 1000})
+	q, err := b.Parse(url.Values{"offset": []string{"1000"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1000, q.Offset)
+
+	_, err = b.Parse(url.Values{"offset": []string{"1001"}})
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, CodeOutOfRange, err.(*ParseError).Code)
+	assert.Equal(t, "offset", err.(*ParseError).Param)
+
+	// unlimited by default.
+	q, err = MustNewBuilder(&Config{Model: model{}}).Parse(url.Values{"offset": []string{"100000"}})
+	require.NoError(t, err)
+	assert.Equal(t, 100000, q.Offset)
+
+	params := OpenAPIParameters(b)
+	assert.Equal(t, "offset", params[1].Name)
+	require.NotNil(t, params[1].Maximum)
+	assert.Equal(t, float64(1000), *params[1].Maximum)
+}
+
+func TestAllowUnlimited(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}, AllowUnlimited: true, LimitMaxValue: 50})
+	q, err := b.Parse(url.Values{"limit": []string{"0"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, q.Limit)
+	assert.Equal(t, Pagination{Unlimited: true, DefaultOffset: true}, q.Pagination)
+	assert.NotContains(t, captureSQL(t, q.Apply), "LIMIT")
+
+	// the default limit is used when the param is missing.
+	q, err = b.Parse(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, 25, q.Limit)
+	assert.False(t, q.Pagination.Unlimited)
+
+	// positive limits are still bounded.
+	_, err = b.Parse(url.Values{"limit": []string{"51"}})
+	assert.IsType(t, &ParseError{}, err)
+
+	// without the option, a zero limit selects all rows as well, but it's not reported.
+	q, err = MustNewBuilder(&Config{Model: pet{}}).Parse(url.Values{"limit": []string{"0"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, q.Limit)
+	assert.Equal(t, Pagination{DefaultOffset: true}, q.Pagination)
+	assert.NotContains(t, captureSQL(t, q.Apply), "LIMIT")
+}
+
+func TestBaseConditions(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model: model{},
+		BaseConditions: []Condition{
+			{Exp: "archived = ?", Vals: []interface{}{false}, OptOutParam: "include_archived"},
+			{Exp: "tenant_id = ?", Vals: []interface{}{42}},
+		},
+	})
+	tests := []struct {
+		name     string
+		params   url.Values
+		wantExp  string
+		wantVals []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "base conditions are added",
+			params:   url.Values{"age_gt": []string{"10"}},
+			wantExp:  "age > ? AND archived = ? AND tenant_id = ?",
+			wantVals: []interface{}{int64(10), false, 42},
+		},
+		{
+			name:     "opt-out param set to false",
+			params:   url.Values{"include_archived": []string{"false"}},
+			wantExp:  "archived = ? AND tenant_id = ?",
+			wantVals: []interface{}{false, 42},
+		},
+		{
+			name:     "opt-out param drops only its condition",
+			params:   url.Values{"include_archived": []string{"true"}},
+			wantExp:  "tenant_id = ?",
+			wantVals: []interface{}{42},
+		},
+		{
+			name:    "invalid opt-out value",
+			params:  url.Values{"include_archived": []string{"maybe"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVals, q.CondVal)
+		})
+	}
+	assert.Empty(t, b.UnknownParams(url.Values{"include_archived": []string{"true"}}))
+}
+
+func TestParseInto(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, DefaultSort: "created_at desc"})
+
+	var q DBQuery
+	err := b.ParseInto(url.Values{
+		"sort":   []string{"-updated_at", "+name", "flag"},
+		"limit":  []string{"10"},
+		"age_gt": []string{"10"},
+	}, &q)
+	assert.NoError(t, err)
+	assert.Equal(t, Pagination{Limit: 10, DefaultOffset: true}, q.Pagination)
+	assert.Equal(t, []SortField{{Column: "updated_at", Desc: true}, {Column: "name"}, {Column: "flag"}}, q.SortFields)
+	assert.Equal(t, "age > ?", q.CondExp)
+	assert.Equal(t, []interface{}{int64(10)}, q.CondVal)
+
+	q = DBQuery{}
+	assert.NoError(t, b.ParseInto(url.Values{}, &q))
+	assert.Equal(t, []SortField{{Column: "created_at", Desc: true}}, q.SortFields)
+
+	q = DBQuery{Limit: 1}
+	assert.Error(t, b.ParseInto(url.Values{"sort": []string{"unknown"}}, &q))
+	assert.Equal(t, DBQuery{Limit: 1}, q, "dest should not be modified on failure")
+}
+
+func TestMultiColumnFilters(t *testing.T) {
+	b, err := NewBuilder(&Config{
+		Model: model{},
+		MultiColumnFilters: map[string][]ColumnFilter{
+			"updated": {{Column: "updated_at", Op: opGreaterThanOrEqual}, {Column: "created_at", Op: opGreaterThanOrEqual}},
+			"who":     {{Column: "name"}, {Column: "status", Op: opLike}},
+		},
+	})
+	assert.NoError(t, err)
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	q, err := b.Parse(url.Values{"updated": []string{date.Format(time.RFC3339)}})
+	assert.NoError(t, err)
+	assert.Equal(t, "(updated_at >= ? OR created_at >= ?)", q.CondExp)
+	assert.Equal(t, []interface{}{date, date}, q.CondVal)
+
+	q, err = b.Parse(url.Values{"who": []string{"a8m"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "(name = ? OR status LIKE ?)", q.CondExp)
+	assert.Equal(t, []interface{}{"a8m", "%a8m%"}, q.CondVal)
+
+	_, err = b.Parse(url.Values{"updated": []string{"yesterday"}})
+	assert.IsType(t, &ParseError{}, err)
+	assert.Empty(t, b.UnknownParams(url.Values{"updated": []string{"yesterday"}}))
+
+	_, err = NewBuilder(&Config{
+		Model:              model{},
+		MultiColumnFilters: map[string][]ColumnFilter{"x": {{Column: "unknown", Op: opEqual}}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewBuilder(&Config{
+		Model:              model{},
+		MultiColumnFilters: map[string][]ColumnFilter{"x": {{Column: "age", Op: opBetween}}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewBuilder(&Config{
+		Model:              model{},
+		MultiColumnFilters: map[string][]ColumnFilter{"name_like": {{Column: "name"}, {Column: "status"}}},
+	})
+	assert.EqualError(t, err, "query: multi-column filter 'name_like' collides with a filter")
+}
+
+func TestDisabledOperators(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, DisabledOperators: []string{opLike, opEqual}})
+	params := url.Values{
+		"name_like": []string{"a8m"},
+		"name_eq":   []string{"a8m"},
+		"name":      []string{"a8m"},
+		"name_neq":  []string{"a8m"},
+	}
+	assert.Equal(t, []string{"name", "name_eq", "name_like"}, b.UnknownParams(params))
+	q, err := b.Parse(params)
+	assert.NoError(t, err)
+	assert.Equal(t, "name <> ?", q.CondExp)
+	assert.Equal(t, []interface{}{"a8m"}, q.CondVal)
+}
+
+// pet is a model used in tests that run queries against a mocked database.
+type pet struct {
+	ID   int
+	Name string `query:"filter,sort"`
+	Age  int    `query:"filter,sort"`
+}
+
+// captureSQL runs a find query on a mocked postgres database, asserts that it was called
+// with the given arguments, and returns its SQL.
+func captureSQL(t *testing.T, apply func(*gorm.DB) *gorm.DB, args ...driver.Value) string {
+	var query string
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		query = actual
+		return nil
+	})))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+	mock.ExpectQuery("").WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	require.NoError(t, apply(db).Find(&[]pet{}).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	return query
+}
+
+func TestScope(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	scope, err := b.Scope(url.Values{"age_gt": []string{"10"}, "sort": []string{"-age"}, "limit": []string{"5"}})
+	require.NoError(t, err)
+	apply := func(db *gorm.DB) *gorm.DB { return db.Scopes(scope) }
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age > $1) ORDER BY age desc LIMIT 5`, captureSQL(t, apply, 10))
+
+	scope, err = b.Scope(url.Values{"age_gt": []string{"ten"}})
+	assert.IsType(t, &ParseError{}, err)
+	assert.Nil(t, scope)
+}
+
+func TestAppliedFilters(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:              pet{},
+		MultiColumnFilters: map[string][]ColumnFilter{"q": {{Column: "name", Op: opLike}}},
+		ComputedFilters:    map[string]string{"label": "name || age"},
+		OrParam:            "or",
+	})
+	q, err := b.Parse(url.Values{
+		"name":     []string{"a8m"},
+		"age_gt":   []string{"1"},
+		"age_lt":   []string{"10"},
+		"q":        []string{"a"},
+		"label":    []string{"a8m"},
+		"or":       []string{"age_gte:2"},
+		"sort":     []string{"name"},
+		"limit":    []string{"5"},
+		"unknown_": []string{"5"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "name"}, q.AppliedFilters)
+
+	q, err = b.Parse(url.Values{"or": []string{"name:a8m", "age_gte:2"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "name"}, q.AppliedFilters, "the columns of the OR group are sorted")
+
+	q, err = b.Parse(url.Values{"sort": []string{"name"}})
+	require.NoError(t, err)
+	assert.Empty(t, q.AppliedFilters)
+}
+
+func TestParseContext(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	params := url.Values{"name": []string{"a8m"}, "sort": []string{"-age"}}
+	q, err := b.ParseContext(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, "name = ?", q.CondExp)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = b.ParseContext(ctx, params)
+	assert.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	_, err = b.ParseContext(ctx, params)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestListAndCount(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+
+	q, err := MustNewBuilder(&Config{Model: pet{}}).Parse(url.Values{"age_gt": []string{"10"}, "sort": []string{"-age"}, "limit": []string{"1"}})
+	require.NoError(t, err)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "pets" WHERE \(age > \$1\)`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "pets"  WHERE \(age > \$1\) ORDER BY age desc LIMIT 1`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age"}).AddRow(1, "a8m", 12))
+
+	var pets []pet
+	total, err := ListAndCount(db, q, &pets)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []pet{{ID: 1, Name: "a8m", Age: 12}}, pets)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(`SELECT count`).WillReturnError(fmt.Errorf("connection refused"))
+	_, err = ListAndCount(db, q, &pets)
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestListAndCountAggregates(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    *Config
+		params    url.Values
+		args      []driver.Value
+		wantCount string
+		wantFind  string
+	}{
+		{
+			name:      "grouped",
+			config:    &Config{Model: pet{}, GroupBy: "age"},
+			params:    url.Values{"age_gt": []string{"10"}},
+			args:      []driver.Value{10},
+			wantCount: `SELECT count(*) FROM (SELECT age FROM "pets"  WHERE (age > $1) GROUP BY age) AS count_table`,
+			wantFind:  `SELECT age FROM "pets"  WHERE (age > $1) GROUP BY age LIMIT 25`,
+		},
+		{
+			name:      "having",
+			config:    &Config{Model: pet{}, GroupBy: "age", HavingFields: map[string]string{"pets": "COUNT(*)"}},
+			params:    url.Values{"age_gt": []string{"10"}, "pets_gt": []string{"1"}},
+			args:      []driver.Value{10, 1.0},
+			wantCount: `SELECT count(*) FROM (SELECT age FROM "pets"  WHERE (age > $1) GROUP BY age HAVING (COUNT(*) > $2)) AS count_table`,
+			wantFind:  `SELECT age FROM "pets"  WHERE (age > $1) GROUP BY age HAVING (COUNT(*) > $2) LIMIT 25`,
+		},
+		{
+			name:      "distinct",
+			config:    &Config{Model: pet{}, ExplicitSelect: true, AllowDistinct: true},
+			params:    url.Values{"age_gt": []string{"10"}, "distinct": []string{"true"}},
+			args:      []driver.Value{10},
+			wantCount: `SELECT count(*) FROM (SELECT DISTINCT id,name,age FROM "pets"  WHERE (age > $1)) AS count_table`,
+			wantFind:  `SELECT DISTINCT id,name,age FROM "pets"  WHERE (age > $1) LIMIT 25`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+				queries = append(queries, strings.TrimSpace(actual))
+				return nil
+			})))
+			require.NoError(t, err)
+			defer sqlDB.Close()
+			db, err := gorm.Open("postgres", sqlDB)
+			require.NoError(t, err)
+
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			mock.ExpectQuery("").WithArgs(tt.args...).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+			mock.ExpectQuery("").WithArgs(tt.args...).WillReturnRows(sqlmock.NewRows([]string{"age"}).AddRow(12))
+
+			var pets []pet
+			total, err := ListAndCount(db, q, &pets)
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, int64(3), total)
+			assert.Equal(t, []string{tt.wantCount, tt.wantFind}, queries)
+		})
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	var calls int
+	b := MustNewBuilder(&Config{
+		Model:           model{},
+		StrictParams:    true,
+		ColumnUsageHook: func([]string) { calls++ },
+	})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr bool
+	}{
+		{name: "valid", params: url.Values{"name": []string{"a8m"}, "age_gt": []string{"1"}, "sort": []string{"-name"}, "limit": []string{"10"}}},
+		{name: "unknown param", params: url.Values{"nam": []string{"a8m"}}, wantErr: true},
+		{name: "invalid value", params: url.Values{"age_gt": []string{"a"}}, wantErr: true},
+		{name: "limit bound", params: url.Values{"limit": []string{"1000"}}, wantErr: true},
+		{name: "enum", params: url.Values{"enum_val": []string{"unknown"}}, wantErr: true},
+		{name: "sort field", params: url.Values{"sort": []string{"status"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := b.ValidateParams(tt.params)
+			_, parseErr := b.Parse(tt.params)
+			assert.Equal(t, parseErr, err, "the result is the same as Parse")
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+	assert.Equal(t, 1, calls, "the hook is called only by Parse")
+}
+
+func TestParseJSON(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	tests := []struct {
+		name     string
+		body     string
+		wantExp  string
+		wantVal  []interface{}
+		wantSort string
+		wantErr  bool
+	}{
+		{
+			name:     "scalars and arrays",
+			body:     `{"filters": {"name": "a8m", "age_in": ["1,2"], "flag": true}, "sort": ["-name"], "limit": 10, "offset": 5}`,
+			wantExp:  "age IN (?) AND flag = ? AND name = ?",
+			wantVal:  []interface{}{[]int64{1, 2}, "true", "a8m"},
+			wantSort: "name desc",
+		},
+		{
+			name:     "multiple values",
+			body:     `{"filters": {"age": [1, 2]}, "sort": "name", "search": "foo"}`,
+			wantExp:  "(age = ? OR age = ?) AND (name = ? OR status LIKE ?)",
+			wantVal:  []interface{}{int64(1), int64(2), "foo", "%foo%"},
+			wantSort: "name",
+		},
+		{
+			name: "null values",
+			body: `{"filters": {"name": null}, "limit": null}`,
+		},
+		{name: "invalid json", body: `{"filters": `, wantErr: true},
+		{name: "nested value", body: `{"filters": {"name": {"eq": "a8m"}}}`, wantErr: true},
+		{name: "invalid filter value", body: `{"filters": {"age_gt": "a"}}`, wantErr: true},
+		{name: "invalid limit", body: `{"limit": 1000}`, wantErr: true},
+		{name: "limit as filter", body: `{"filters": {"limit": 1000}, "limit": 10}`, wantErr: true},
+		{name: "sort as filter", body: `{"filters": {"sort": "-name"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.ParseJSON(strings.NewReader(tt.body))
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+			assert.Equal(t, tt.wantSort, q.Sort)
+		})
+	}
+	q, err := b.ParseJSON(strings.NewReader(`{"limit": 10, "offset": 5}`))
+	require.NoError(t, err)
+	assert.Equal(t, 10, q.Limit)
+	assert.Equal(t, 5, q.Offset)
+
+	// the params are matched case-insensitively, if it's enabled.
+	b = MustNewBuilder(&Config{Model: model{}, CaseInsensitiveParams: true})
+	_, err = b.ParseJSON(strings.NewReader(`{"filters": {"Offset": 5}}`))
+	assert.EqualError(t, err, "key 'Offset' is not a filter")
+	q, err = b.ParseJSON(strings.NewReader(`{"filters": {"Name": "a8m"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "name = ?", q.CondExp)
+
+	// only filters are accepted, and the control params are rejected.
+	b = MustNewBuilder(&Config{
+		Model:              pet{},
+		CursorField:        "age",
+		OrParam:            "or",
+		AllowDistinct:      true,
+		BaseConditions:     []Condition{{Exp: "archived = ?", Vals: []interface{}{false}, OptOutParam: "include_archived"}},
+		MultiColumnFilters: map[string][]ColumnFilter{"q": {{Column: "name"}, {Column: "age"}}},
+	})
+	for _, key := range []string{"after", "before", "or", "distinct", "include_archived", "unknown"} {
+		_, err = b.ParseJSON(strings.NewReader(`{"filters": {"` + key + `": "1"}}`))
+		require.IsType(t, &ParseError{}, err, key)
+		assert.Equal(t, CodeUnknownField, err.(*ParseError).Code, key)
+	}
+	q, err = b.ParseJSON(strings.NewReader(`{"filters": {"q": "1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "(name = ? OR age = ?) AND archived = ?", q.CondExp)
+}
+
+func TestApplyCount(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	q, err := b.Parse(url.Values{
+		"age_gt": []string{"10"},
+		"sort":   []string{"-age"},
+		"limit":  []string{"5"},
+		"offset": []string{"10"},
+	})
+	require.NoError(t, err)
+
+	var query string
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		query = actual
+		return nil
+	})))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+	mock.ExpectQuery("").WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	var total int
+	require.NoError(t, q.ApplyCount(db.Model(&pet{})).Count(&total).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 42, total)
+	assert.Equal(t, `SELECT count(*) FROM "pets"  WHERE (age > $1)`, query)
+
+	// the data query is not affected.
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age > $1) ORDER BY age desc LIMIT 5 OFFSET 10`, captureSQL(t, q.Apply, 10))
+
+	// grouped queries are counted by their groups.
+	q = &DBQuery{Select: "owner_id", CondExp: "age > ?", CondVal: []interface{}{10}, GroupBy: "owner_id"}
+	mock.ExpectQuery("").WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	require.NoError(t, q.ApplyCount(db.Model(&pet{})).Count(&total).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 3, total)
+	assert.Equal(t, `SELECT count(*) FROM (SELECT owner_id FROM "pets"  WHERE (age > $1) GROUP BY owner_id) AS count_table`, strings.TrimSpace(query))
+
+	// the having condition is applied to the groups in the subquery.
+	q.Select, q.HavingExp, q.HavingVal = "owner_id, count(*)", "count(*) > ?", []interface{}{2}
+	mock.ExpectQuery("").WithArgs(10, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	require.NoError(t, q.ApplyCount(db.Model(&pet{})).Count(&total).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 1, total)
+	assert.Equal(t, `SELECT count(*) FROM (SELECT owner_id, count(*) FROM "pets"  WHERE (age > $1) GROUP BY owner_id HAVING (count(*) > $2)) AS count_table`, strings.TrimSpace(query))
+
+	// distinct queries are counted by their distinct rows.
+	q = &DBQuery{Select: "DISTINCT name"}
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	require.NoError(t, q.ApplyCount(db.Model(&pet{})).Count(&total).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 7, total)
+	assert.Equal(t, `SELECT count(*) FROM (SELECT DISTINCT name FROM "pets"  ) AS count_table`, strings.TrimSpace(query))
+}
+
+func TestApplyChainable(t *testing.T) {
+	q, err := MustNewBuilder(&Config{Model: pet{}}).Parse(url.Values{"name": []string{"a8m"}})
+	require.NoError(t, err)
+
+	var query string
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		query = actual
+		return nil
+	})))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	db, err := gorm.Open("postgres", sqlDB)
+	require.NoError(t, err)
+
+	// no query is executed until the caller calls a finisher method.
+	prepared := q.Apply(db).Joins("JOIN owners ON owners.id = pets.owner_id")
+	require.NoError(t, prepared.Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, query)
+
+	mock.ExpectQuery("").WithArgs("a8m").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	require.NoError(t, prepared.Find(&[]pet{}).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, `SELECT "pets".* FROM "pets" JOIN owners ON owners.id = pets.owner_id WHERE (name = $1) LIMIT 25`, query)
+}
+
+func TestAndOr(t *testing.T) {
+	q := &DBQuery{}
+	q.Or("owner_id = ?", 1)
+	assert.Equal(t, "owner_id = ?", q.CondExp)
+	assert.Equal(t, []interface{}{1}, q.CondVal)
+
+	q.And("age > ?", 10)
+	q.Or("is_public = ?", true)
+	assert.Equal(t, "(owner_id = ? AND age > ?) OR (is_public = ?)", q.CondExp)
+	assert.Equal(t, []interface{}{1, 10, true}, q.CondVal)
+	assert.Equal(t, []Clause{{Exp: q.CondExp, Vals: q.CondVal}}, q.Clauses)
+
+	// the OR expression is parenthesized before adding a condition with And.
+	q.And("name = ?", "a8m")
+	assert.Equal(t, "((owner_id = ? AND age > ?) OR (is_public = ?)) AND name = ?", q.CondExp)
+	assert.Equal(t, []interface{}{1, 10, true, "a8m"}, q.CondVal)
+	assert.Len(t, q.Clauses, 2)
+}
+
+func TestApplyStructured(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	q, err := b.Parse(url.Values{
+		"name": []string{"a8m", "pos"},
+		"sort": []string{"-age"},
+	})
+	require.NoError(t, err)
+	q.And("age > ?", 10)
+	require.Len(t, q.Clauses, 2)
+
+	combined := captureSQL(t, q.Apply, "a8m", "pos", 10)
+	structured := captureSQL(t, q.ApplyStructured, "a8m", "pos", 10)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE ((name = $1 OR name = $2) AND age > $3) ORDER BY age desc LIMIT 25`, combined)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE ((name = $1 OR name = $2)) AND (age > $3) ORDER BY age desc LIMIT 25`, structured)
+
+	// fallback to the combined expression.
+	q = &DBQuery{CondExp: "age > ?", CondVal: []interface{}{10}}
+	assert.Equal(t, captureSQL(t, q.Apply, 10), captureSQL(t, q.ApplyStructured, 10))
+}
+
+func TestDurationFields(t *testing.T) {
+	type task struct {
+		Timeout  time.Duration  `query:"filter"`
+		Interval *time.Duration `query:"filter"`
+	}
+	tests := []struct {
+		name    string
+		config  *Config
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "go format",
+			config:  &Config{Model: task{}},
+			params:  url.Values{"timeout_gt": []string{"1h30m"}},
+			wantExp: "timeout > ?",
+			wantVal: []interface{}{90 * time.Minute},
+		},
+		{
+			name:    "iso format is disabled by default",
+			config:  &Config{Model: task{}},
+			params:  url.Values{"timeout_gt": []string{"PT1H30M"}},
+			wantErr: true,
+		},
+		{
+			name:    "iso format",
+			config:  &Config{Model: task{}, AllowISODurations: true},
+			params:  url.Values{"timeout_gt": []string{"PT1H30M"}},
+			wantExp: "timeout > ?",
+			wantVal: []interface{}{90 * time.Minute},
+		},
+		{
+			name:    "go format when iso format is enabled",
+			config:  &Config{Model: task{}, AllowISODurations: true},
+			params:  url.Values{"interval_lte": []string{"1h30m"}},
+			wantExp: "interval <= ?",
+			wantVal: []interface{}{90 * time.Minute},
+		},
+		{
+			name:    "iso format with days and fractional seconds",
+			config:  &Config{Model: task{}, AllowISODurations: true},
+			params:  url.Values{"interval": []string{"P1DT0.5S"}},
+			wantExp: "interval = ?",
+			wantVal: []interface{}{24*time.Hour + 500*time.Millisecond},
+		},
+		{
+			name:    "invalid iso format",
+			config:  &Config{Model: task{}, AllowISODurations: true},
+			params:  url.Values{"timeout": []string{"PT"}},
+			wantErr: true,
+		},
+		{
+			name:    "iso format with years is not supported",
+			config:  &Config{Model: task{}, AllowISODurations: true},
+			params:  url.Values{"timeout": []string{"P1Y"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+func TestReservedParams(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, ReservedParams: []string{"include", "expand"}})
+	params := url.Values{
+		"include": []string{"owner"},
+		"expand":  []string{"true"},
+		"trace":   []string{"1"},
+		"age_gt":  []string{"10"},
+	}
+	assert.Equal(t, []string{"trace"}, b.UnknownParams(params))
+	q, err := b.Parse(params)
+	assert.NoError(t, err)
+	assert.Equal(t, "age > ?", q.CondExp)
+}
+
+func TestReservedParamNames(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	assert.Equal(t, []string{"limit", "offset", "sort"}, b.ReservedParams())
+
+	b = MustNewBuilder(&Config{
+		Model:          model{},
+		LimitParam:     "size",
+		OrParam:        "or",
+		AllowDistinct:  true,
+		ReservedParams: []string{"expand", "sort"},
+	})
+	assert.Equal(t, []string{"distinct", "expand", "offset", "or", "search", "size", "sort"}, b.ReservedParams())
+
+	_, err := NewBuilder(&Config{Model: struct {
+		Limit int `query:"filter"`
+	}{}})
+	assert.EqualError(t, err, "query: filter 'limit' collides with a reserved param")
+	_, err = NewBuilder(&Config{Model: pet{}, ReservedParams: []string{"name"}})
+	assert.EqualError(t, err, "query: filter 'name' collides with a reserved param")
+	_, err = NewBuilder(&Config{Model: pet{}, ComputedFilters: map[string]string{"offset": "age"}})
+	assert.EqualError(t, err, "query: filter 'offset' collides with a reserved param")
+	// only exact names are reserved.
+	_, err = NewBuilder(&Config{Model: struct {
+		Limit int `query:"filter"`
+	}{}, LimitParam: "size"})
+	assert.NoError(t, err)
+}
+
+func TestInOperator(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	q, err := b.Parse(url.Values{
+		"age_in":          []string{"1,2", "3"},
+		"name_not_in":     []string{"a8m,pos"},
+		"tag_name_in":     []string{"a"},
+		"enum_val_not_in": []string{"v1"},
+	})
+	require.NoError(t, err)
+	actual := strings.Split(q.CondExp, " AND ")
+	sort.Strings(actual)
+	assert.Equal(t, []string{
+		"(name IN (SELECT DISTINCT tag_name IN tags WHERE tag_name IN (?)))",
+		"age IN (?)",
+		"enum_val NOT IN (?)",
+		"name NOT IN (?)",
+	}, actual)
+	assert.Len(t, q.CondVal, 4)
+	assert.Contains(t, q.CondVal, []int64{1, 2, 3})
+	assert.Contains(t, q.CondVal, []string{"a8m", "pos"})
+	assert.Contains(t, q.CondVal, []interface{}{"a"})
+	assert.Contains(t, q.CondVal, []MyEnum{enumVal1})
+
+	_, err = b.Parse(url.Values{"age_in": []string{"1,,2"}})
+	assert.IsType(t, &ParseError{}, err)
+
+	// gorm expands the slice argument.
+	q, err = MustNewBuilder(&Config{Model: pet{}}).Parse(url.Values{"age_in": []string{"1,2,3"}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age IN ($1,$2,$3)) LIMIT 25`, captureSQL(t, q.Apply, 1, 2, 3))
+}
+
+func TestLikeAnyArray(t *testing.T) {
+	params := url.Values{"name_like": []string{"a8m", "pos"}}
+	tests := []struct {
+		name    string
+		config  *Config
+		wantExp string
+	}{
+		{
+			name:    "postgres",
+			config:  &Config{Model: model{}, Dialect: DialectPostgres, LikeAnyArray: true},
+			wantExp: "name LIKE ANY(ARRAY[?, ?])",
+		},
+		{
+			name:    "postgres without the option",
+			config:  &Config{Model: model{}, Dialect: DialectPostgres},
+			wantExp: "(name LIKE ? OR name LIKE ?)",
+		},
+		{
+			name:    "fallback on other dialects",
+			config:  &Config{Model: model{}, Dialect: DialectMySQL, LikeAnyArray: true},
+			wantExp: "(name LIKE ? OR name LIKE ?)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, []interface{}{"%a8m%", "%pos%"}, q.CondVal)
+		})
+	}
+	// a single value is not affected.
+	q, err := MustNewBuilder(tests[0].config).Parse(url.Values{"name_like": []string{"a8m"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name LIKE ?", q.CondExp)
+}
+
+// lowerName is a string field that delegates its expressions to the lower-cased column.
+type lowerName string
+
+func (lowerName) Wrap(s string) string { return strings.Replace(s, "nick", "LOWER(nick)", 1) }
+
+func TestStartsEndsWithOperators(t *testing.T) {
+	type user struct {
+		Name string    `query:"filter"`
+		Nick lowerName `query:"filter"`
+	}
+	tests := []struct {
+		name    string
+		config  *Config
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "starts with",
+			config:  &Config{Model: user{}},
+			params:  url.Values{"name_starts_with": []string{"ab"}},
+			wantExp: "name LIKE ?",
+			wantVal: []interface{}{"ab%"},
+		},
+		{
+			name:    "ends with",
+			config:  &Config{Model: user{}},
+			params:  url.Values{"name_ends_with": []string{"xy", "z"}},
+			wantExp: "(name LIKE ? OR name LIKE ?)",
+			wantVal: []interface{}{"%xy", "%z"},
+		},
+		{
+			name:    "wrapper",
+			config:  &Config{Model: user{}},
+			params:  url.Values{"nick_starts_with": []string{"ab"}},
+			wantExp: "LOWER(nick) LIKE ?",
+			wantVal: []interface{}{"ab%"},
+		},
+		{
+			name:    "postgres any array",
+			config:  &Config{Model: user{}, Dialect: DialectPostgres, LikeAnyArray: true},
+			params:  url.Values{"name_starts_with": []string{"ab", "cd"}},
+			wantExp: "name LIKE ANY(ARRAY[?, ?])",
+			wantVal: []interface{}{"ab%", "cd%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	_, err := MustNewBuilder(&Config{Model: user{}}).Parse(url.Values{"name_starts_with": []string{""}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestRegexOperator(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		params  url.Values
+		wantExp string
+		wantErr bool
+	}{
+		{
+			name:    "postgres",
+			dialect: DialectPostgres,
+			params:  url.Values{"name_re": []string{"^ab.*$"}},
+			wantExp: "name ~ ?",
+		},
+		{
+			name:    "mysql",
+			dialect: DialectMySQL,
+			params:  url.Values{"name_re": []string{"^ab.*$"}},
+			wantExp: "name REGEXP ?",
+		},
+		{
+			name:    "invalid pattern",
+			dialect: DialectPostgres,
+			params:  url.Values{"name_re": []string{"(ab"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(&Config{Model: model{}, Dialect: tt.dialect}).Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, []interface{}{"^ab.*$"}, q.CondVal)
+		})
+	}
+	// the operator is not registered without a dialect.
+	b := MustNewBuilder(&Config{Model: model{}})
+	assert.Equal(t, []string{"name_re"}, b.UnknownParams(url.Values{"name_re": []string{"^ab"}}))
+}
+
+func TestArrayOperators(t *testing.T) {
+	type post struct {
+		Tags   []string `query:"filter"`
+		Scores []int64  `query:"filter"`
+	}
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "contains",
+			params:  url.Values{"tags_contains": []string{"red,blue"}},
+			wantExp: "tags && ?",
+			wantVal: []interface{}{`{"red","blue"}`},
+		},
+		{
+			name:    "contains all",
+			params:  url.Values{"tags_contains_all": []string{"red,blue"}},
+			wantExp: "tags @> ?",
+			wantVal: []interface{}{`{"red","blue"}`},
+		},
+		{
+			name:    "escaped values",
+			params:  url.Values{"tags_contains": []string{`a"b,c\d`}},
+			wantExp: "tags && ?",
+			wantVal: []interface{}{`{"a\"b","c\\d"}`},
+		},
+		{
+			name:    "int elements",
+			params:  url.Values{"scores_contains": []string{"1,2"}},
+			wantExp: "scores && ?",
+			wantVal: []interface{}{`{"1","2"}`},
+		},
+		{
+			name:    "invalid element",
+			params:  url.Values{"scores_contains_all": []string{"1,a"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty element",
+			params:  url.Values{"tags_contains": []string{"red,"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(&Config{Model: post{}, Dialect: DialectPostgres}).Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// the operators are registered only on postgres.
+	b := MustNewBuilder(&Config{Model: struct {
+		Tags []string `query:"filter"`
+	}{}, Dialect: DialectMySQL})
+	assert.Equal(t, []string{"tags_contains"}, b.UnknownParams(url.Values{"tags_contains": []string{"red"}}))
+}
+
+func TestCaseInsensitiveEquality(t *testing.T) {
+	type user struct {
+		Email string    `query:"filter"`
+		Nick  lowerName `query:"filter"`
+		Alias *string   `query:"filter"`
+		Enum  MyEnum    `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: user{}, NullSafeNeq: true})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "ieq",
+			params:  url.Values{"email_ieq": []string{"Foo@Bar.com"}},
+			wantExp: "LOWER(email) = LOWER(?)",
+			wantVal: []interface{}{"Foo@Bar.com"},
+		},
+		{
+			name:    "ineq",
+			params:  url.Values{"email_ineq": []string{"Foo@Bar.com"}},
+			wantExp: "LOWER(email) <> LOWER(?)",
+			wantVal: []interface{}{"Foo@Bar.com"},
+		},
+		{
+			name:    "multiple values",
+			params:  url.Values{"email_ieq": []string{"A@b.com", "c@D.com"}},
+			wantExp: "(LOWER(email) = LOWER(?) OR LOWER(email) = LOWER(?))",
+			wantVal: []interface{}{"A@b.com", "c@D.com"},
+		},
+		{
+			name:    "wrapper",
+			params:  url.Values{"nick_ieq": []string{"A8m"}},
+			wantExp: "LOWER(LOWER(nick)) = LOWER(?)",
+			wantVal: []interface{}{"A8m"},
+		},
+		{
+			name:    "null safe",
+			params:  url.Values{"alias_ineq": []string{"A8m"}},
+			wantExp: "(LOWER(alias) <> LOWER(?) OR alias IS NULL)",
+			wantVal: []interface{}{"A8m"},
+		},
+		{
+			name:    "enum",
+			params:  url.Values{"enum_ieq": []string{"V1"}},
+			wantExp: "LOWER(enum) = LOWER(?)",
+			wantVal: []interface{}{"V1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+// tagSet matches pets by their tags, with a subquery that binds the owner id as well.
+type tagSet string
+
+func (tagSet) WrapValues(exp string, vals []interface{}) (string, []interface{}) {
+	return "(id IN (SELECT pet_id FROM tags WHERE " + exp + " AND owner_id = ?))", append(vals, 1)
+}
+
+// Wrap is ignored, as tagSet implements the ValueWrapper interface.
+func (tagSet) Wrap(s string) string { return "ignored" }
+
+func TestValueWrapper(t *testing.T) {
+	type pet struct {
+		Name string `query:"filter"`
+		Tag  tagSet `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{
+		Model:              pet{},
+		MultiColumnFilters: map[string][]ColumnFilter{"label": {{Column: "name"}, {Column: "tag"}}},
+	})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "single value",
+			params:  url.Values{"tag": []string{"red"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE tag = ? AND owner_id = ?))",
+			wantVal: []interface{}{"red", 1},
+		},
+		{
+			name:    "multiple values",
+			params:  url.Values{"tag": []string{"red", "blue"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE (tag = ? OR tag = ?) AND owner_id = ?))",
+			wantVal: []interface{}{"red", "blue", 1},
+		},
+		{
+			name:    "list",
+			params:  url.Values{"tag_in": []string{"red,blue"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE tag IN (?) AND owner_id = ?))",
+			wantVal: []interface{}{[]tagSet{"red", "blue"}, 1},
+		},
+		{
+			name:    "with other filters",
+			params:  url.Values{"tag": []string{"red"}, "name": []string{"a8m"}},
+			wantExp: "name = ? AND (id IN (SELECT pet_id FROM tags WHERE tag = ? AND owner_id = ?))",
+			wantVal: []interface{}{"a8m", "red", 1},
+		},
+		{
+			name:    "multi-column filter",
+			params:  url.Values{"label": []string{"red"}},
+			wantExp: "(name = ? OR (id IN (SELECT pet_id FROM tags WHERE tag = ? AND owner_id = ?)))",
+			wantVal: []interface{}{"red", "red", 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+// hexID is a fixed-size identifier that is decoded from its hex form (like uuid.UUID).
+type hexID [4]byte
+
+func (h *hexID) UnmarshalText(b []byte) error {
+	if hex.DecodedLen(len(b)) != len(h) {
+		return errors.New("invalid id length")
+	}
+	_, err := hex.Decode(h[:], b)
+	return err
+}
+
+func (h hexID) String() string { return hex.EncodeToString(h[:]) }
+
+func TestTextUnmarshalerFields(t *testing.T) {
+	type pet struct {
+		ID      hexID  `query:"filter"`
+		OwnerID *hexID `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: pet{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "canonical form",
+			params:  url.Values{"id": []string{"DEADBEEF"}},
+			wantExp: "id = ?",
+			wantVal: []interface{}{"deadbeef"},
+		},
+		{
+			name:    "neq",
+			params:  url.Values{"owner_id_neq": []string{"0a0b0c0d"}},
+			wantExp: "owner_id <> ?",
+			wantVal: []interface{}{"0a0b0c0d"},
+		},
+		{
+			name:    "in",
+			params:  url.Values{"id_in": []string{"deadbeef,0A0B0C0D"}},
+			wantExp: "id IN (?)",
+			wantVal: []interface{}{[]interface{}{"deadbeef", "0a0b0c0d"}},
+		},
+		{
+			name:    "malformed",
+			params:  url.Values{"id": []string{"not-an-id"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			params:  url.Values{"id_eq": []string{""}},
+			wantErr: true,
+		},
+		{
+			name:    "no pattern operators",
+			params:  url.Values{"id_like": []string{"dead%"}},
+			wantExp: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			if tt.wantVal != nil {
+				assert.Equal(t, tt.wantVal, q.CondVal)
+			}
+		})
+	}
+}
+
+func TestILikeOperator(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "postgres",
+			config:  &Config{Model: model{}, Dialect: DialectPostgres},
+			params:  url.Values{"name_ilike": []string{"A8m"}},
+			wantExp: "name ILIKE ?",
+			wantVal: []interface{}{"%A8m%"},
+		},
+		{
+			name:    "mysql",
+			config:  &Config{Model: model{}, Dialect: DialectMySQL},
+			params:  url.Values{"name_ilike": []string{"A8m"}},
+			wantExp: "LOWER(name) LIKE LOWER(?)",
+			wantVal: []interface{}{"%A8m%"},
+		},
+		{
+			name:    "default dialect",
+			config:  &Config{Model: model{}},
+			params:  url.Values{"name_ilike": []string{"A8m", "pos"}},
+			wantExp: "(LOWER(name) LIKE LOWER(?) OR LOWER(name) LIKE LOWER(?))",
+			wantVal: []interface{}{"%A8m%", "%pos%"},
+		},
+		{
+			name:    "postgres any array",
+			config:  &Config{Model: model{}, Dialect: DialectPostgres, LikeAnyArray: true},
+			params:  url.Values{"name_ilike": []string{"A8m", "pos"}},
+			wantExp: "name ILIKE ANY(ARRAY[?, ?])",
+			wantVal: []interface{}{"%A8m%", "%pos%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// numeric fields do not support the operator.
+	assert.Equal(t, []string{"age_ilike"}, MustNewBuilder(&Config{Model: model{}}).UnknownParams(url.Values{"age_ilike": []string{"1"}}))
+}
+
+func TestComputedFilters(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:           pet{},
+		ComputedFilters: map[string]string{"full_name": "first_name || ' ' || last_name"},
+	})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "like",
+			params:  url.Values{"full_name_like": []string{"john doe"}},
+			wantExp: "(first_name || ' ' || last_name) LIKE ?",
+			wantVal: []interface{}{"%john doe%"},
+		},
+		{
+			name:    "eq",
+			params:  url.Values{"full_name": []string{"john doe"}},
+			wantExp: "(first_name || ' ' || last_name) = ?",
+			wantVal: []interface{}{"john doe"},
+		},
+		{
+			name:    "in",
+			params:  url.Values{"full_name_in": []string{"a b,c d"}},
+			wantExp: "(first_name || ' ' || last_name) IN (?)",
+			wantVal: []interface{}{[]string{"a b", "c d"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	// the expression is not passed to the column rewriter.
+	q, err := b.ParseWithColumnRewriter(url.Values{"full_name_like": []string{"j"}, "name": []string{"a8m"}}, func(c string) string { return "pets." + c })
+	require.NoError(t, err)
+	assert.Equal(t, "(first_name || ' ' || last_name) LIKE ? AND pets.name = ?", q.CondExp)
+
+	_, err = NewBuilder(&Config{Model: pet{}, ComputedFilters: map[string]string{"name": "first_name || last_name"}})
+	assert.EqualError(t, err, "query: computed filter 'name' collides with a filter")
+}
+
+func TestDefaultOrderDirection(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, DefaultOrderDirection: "desc"})
+	q, err := b.Parse(url.Values{"sort": []string{"created_at", "+name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "created_at desc, name asc", q.Sort)
+	assert.Equal(t, []SortField{{Column: "created_at", Desc: true}, {Column: "name"}}, q.SortFields)
+
+	q, err = MustNewBuilder(&Config{Model: model{}}).Parse(url.Values{"sort": []string{"created_at"}})
+	require.NoError(t, err)
+	assert.Equal(t, "created_at", q.Sort, "ascending by default")
+
+	_, err = NewBuilder(&Config{Model: model{}, DefaultOrderDirection: "down"})
+	assert.Error(t, err)
+}
+
+func TestSortDescOption(t *testing.T) {
+	type event struct {
+		Name      string    `query:"sort"`
+		CreatedAt time.Time `query:"sort,sortdesc"`
+	}
+	b := MustNewBuilder(&Config{Model: event{}})
+	tests := []struct {
+		sort []string
+		want string
+	}{
+		{sort: []string{"created_at"}, want: "created_at desc"},
+		{sort: []string{"+created_at"}, want: "created_at asc"},
+		{sort: []string{"-created_at"}, want: "created_at desc"},
+		{sort: []string{"name", "created_at"}, want: "name, created_at desc"},
+	}
+	for _, tt := range tests {
+		q, err := b.Parse(url.Values{"sort": tt.sort})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, q.Sort)
+	}
+}
+
+func TestDuplicateSort(t *testing.T) {
+	params := url.Values{"sort": []string{"name", "-name"}}
+	_, err := MustNewBuilder(&Config{Model: model{}}).Parse(params)
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, "duplicate sort parameter 'name'", err.Error())
+	assert.Equal(t, "sort", err.(*ParseError).Param)
+
+	q, err := MustNewBuilder(&Config{Model: model{}, AllowDuplicateSort: true}).Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, "name, name desc", q.Sort)
+}
+
+func TestClone(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, DefaultLimit: 10})
+	clone := b.Clone()
+	clone.DefaultLimit = 50
+	clone.IgnoreSort = true
+	clone.filterFields["other"] = filterField{}
+
+	params := url.Values{"name": []string{"a8m"}, "sort": []string{"name"}}
+	q, err := b.Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, 10, q.Limit)
+	assert.Equal(t, "name", q.Sort)
+	_, ok := b.filterFields["other"]
+	assert.False(t, ok, "the fields of the original builder are not affected")
+
+	q, err = clone.Parse(params)
+	require.NoError(t, err)
+	assert.Equal(t, 50, q.Limit)
+	assert.Empty(t, q.Sort)
+	assert.Equal(t, "name = ?", q.CondExp)
+}
+
+func TestFieldsCache(t *testing.T) {
+	type cached struct {
+		Name string `query:"filter,sort"`
+		Age  int    `query:"filter"`
+	}
+	b1 := MustNewBuilder(&Config{Model: cached{}})
+	b2 := MustNewBuilder(&Config{Model: cached{}, ComputedFilters: map[string]string{"label": "name || age"}})
+	_, ok := b1.filterFields["label"]
+	assert.False(t, ok, "the cached fields are not shared between builders")
+	_, ok = b2.filterFields["label"]
+	assert.True(t, ok)
+
+	// the config options of the fields are part of the cache key.
+	b3 := MustNewBuilder(&Config{Model: cached{}, Separator: "__"})
+	q, err := b3.Parse(url.Values{"age__gt": []string{"1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "age > ?", q.CondExp)
+	assert.Equal(t, []string{"age__gt"}, b1.UnknownParams(url.Values{"age__gt": []string{"1"}}))
+
+	// the parsers that depend on the config are bound to each builder, and to each clone.
+	type event struct {
+		At   time.Time `query:"filter"`
+		Done bool      `query:"filter"`
+	}
+	b1 = MustNewBuilder(&Config{Model: event{}})
+	b2 = MustNewBuilder(&Config{Model: event{}, BoolValues: map[string]bool{"yes": true}, TimeFormats: []string{"2006-01-02"}})
+	q, err = b2.Parse(url.Values{"done": []string{"yes"}, "at": []string{"2020-01-02"}})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "true"}, q.CondVal)
+	_, err = b1.Parse(url.Values{"done": []string{"yes"}})
+	assert.Error(t, err)
+
+	loc := time.FixedZone("UTC+1", 3600)
+	clone := b1.Clone()
+	clone.Location = loc
+	q, err = clone.Parse(url.Values{"at": []string{"2020-01-02T00:00:00Z"}})
+	require.NoError(t, err)
+	assert.Equal(t, loc, q.CondVal[0].(time.Time).Location())
+	q, err = b1.Parse(url.Values{"at": []string{"2020-01-02T00:00:00Z"}})
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, q.CondVal[0].(time.Time).Location())
+}
+
+func TestColumnOption(t *testing.T) {
+	type user struct {
+		UserName string `query:"filter,sort,column=usr_name"`
+		Email    string `query:"filter,param=mail,column=usr_email"`
+		Nick     string `query:"filter,param=alias"`
+	}
+	b := MustNewBuilder(&Config{Model: user{}, ExplicitSelect: true})
+	tests := []struct {
+		params  url.Values
+		wantExp string
+	}{
+		{params: url.Values{"user_name": []string{"a8m"}}, wantExp: "usr_name = ?"},
+		{params: url.Values{"mail_like": []string{"a8m"}}, wantExp: "usr_email LIKE ?"},
+		{params: url.Values{"alias": []string{"a8m"}}, wantExp: "alias = ?"},
+	}
+	for _, tt := range tests {
+		q, err := b.Parse(tt.params)
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantExp, q.CondExp)
+	}
+	assert.Equal(t, []string{"usr_name", "usr_email", "nick"}, b.selectFields)
+	assert.Equal(t, []string{"usr_name"}, b.UnknownParams(url.Values{"usr_name": []string{"a8m"}}))
+	q, err := b.Parse(url.Values{"sort": []string{"user_name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "usr_name", q.Sort)
+	// each struct field is described once, by its param and its column.
+	fields := b.Fields()
+	require.Len(t, fields, 3)
+	assert.Equal(t, []string{"alias", "mail", "user_name"}, []string{fields[0].Name, fields[1].Name, fields[2].Name})
+	assert.Equal(t, []string{"nick", "usr_email", "usr_name"}, []string{fields[0].Column, fields[1].Column, fields[2].Column})
+	assert.True(t, fields[1].Selected)
+	assert.NotEmpty(t, fields[1].Operators)
+	assert.True(t, fields[2].Sortable)
+}
+
+type Timestamps struct {
+	CreatedAt time.Time `query:"filter,sort"`
+	UpdatedAt time.Time `query:"filter"`
+}
+
+func TestEmbeddedPointer(t *testing.T) {
+	type post struct {
+		*Timestamps
+		Title string `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: post{}, ExplicitSelect: true})
+	assert.Contains(t, b.filterFields, "created_at_gt")
+	assert.Contains(t, b.filterFields, "updated_at")
+	assert.True(t, b.sortFields["created_at"])
+	assert.Equal(t, []string{"title", "created_at", "updated_at"}, b.selectFields)
+
+	q, err := b.Parse(url.Values{"created_at_gt": []string{"2020-01-01T00:00:00Z"}, "sort": []string{"-created_at"}})
+	require.NoError(t, err)
+	assert.Equal(t, "created_at > ?", q.CondExp)
+	assert.Equal(t, "created_at desc", q.Sort)
+}
+
+func TestStableSortKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		params url.Values
+		want   string
+	}{
+		{
+			name:   "appended",
+			config: &Config{Model: model{}, StableSortKey: "id"},
+			params: url.Values{"sort": []string{"-created_at"}},
+			want:   "created_at desc, id",
+		},
+		{
+			name:   "default sort",
+			config: &Config{Model: model{}, StableSortKey: "id", DefaultSort: "name"},
+			want:   "name, id",
+		},
+		{
+			name:   "already present",
+			config: &Config{Model: model{}, StableSortKey: "name"},
+			params: url.Values{"sort": []string{"-name", "created_at"}},
+			want:   "name desc, created_at",
+		},
+		{
+			name:   "no sort",
+			config: &Config{Model: model{}, StableSortKey: "id"},
+		},
+		{
+			name:   "disabled",
+			config: &Config{Model: model{}},
+			params: url.Values{"sort": []string{"name"}},
+			want:   "name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, q.Sort)
+		})
+	}
+}
+
+func TestSplitOnComma(t *testing.T) {
+	type item struct {
+		Name  string `query:"filter"`
+		Code  string `query:"filter,split"`
+		Title string `query:"filter,nosplit"`
+	}
+	tests := []struct {
+		name    string
+		config  *Config
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "split tag",
+			config:  &Config{Model: item{}},
+			params:  url.Values{"code": []string{"a,b"}},
+			wantExp: "(code = ? OR code = ?)",
+			wantVal: []interface{}{"a", "b"},
+		},
+		{
+			name:    "disabled by default",
+			config:  &Config{Model: item{}},
+			params:  url.Values{"name": []string{"a,b"}},
+			wantExp: "name = ?",
+			wantVal: []interface{}{"a,b"},
+		},
+		{
+			name:    "enabled globally",
+			config:  &Config{Model: item{}, SplitOnComma: true},
+			params:  url.Values{"name": []string{"a,b"}},
+			wantExp: "(name = ? OR name = ?)",
+			wantVal: []interface{}{"a", "b"},
+		},
+		{
+			name:    "opt out",
+			config:  &Config{Model: item{}, SplitOnComma: true},
+			params:  url.Values{"title": []string{"a,b"}},
+			wantExp: "title = ?",
+			wantVal: []interface{}{"a,b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+func TestDefaultSortValidation(t *testing.T) {
+	// untagged columns, qualified columns and expressions are valid as well.
+	for _, sort := range []string{"name", "name desc, created_at", " created_at DESC ", "name, status", "models.name", "lower(name)"} {
+		_, err := NewBuilder(&Config{Model: model{}, DefaultSort: sort})
+		assert.NoError(t, err, sort)
+	}
+	_, err := NewBuilder(&Config{Model: model{}, DefaultSort: "naem desc"})
+	assert.EqualError(t, err, "query: default sort of unknown column 'naem'")
+	_, err = NewBuilder(&Config{Model: model{}, DefaultSort: "dummy"})
+	assert.EqualError(t, err, "query: default sort of unknown column 'dummy'", "dummy is ignored by gorm")
+
+	// the columns are resolved by the gorm tag, and the stable sort key is compared with them.
+	type user struct {
+		ID       int
+		UserName string `query:"sort" gorm:"column:usr_name"`
+	}
+	b := MustNewBuilder(&Config{Model: user{}, DefaultSort: "id", StableSortKey: "usr_name"})
+	q, err := b.Parse(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, "id, usr_name", q.Sort)
+	q, err = b.Parse(url.Values{"sort": []string{"user_name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "usr_name", q.Sort)
+	_, err = NewBuilder(&Config{Model: user{}, DefaultSort: "user_name"})
+	assert.EqualError(t, err, "query: default sort of unknown column 'user_name'")
+}
+
+func TestMaxSortFields(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}, MaxSortFields: 2})
+	q, err := b.Parse(url.Values{"sort": []string{"name", "-created_at"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name, created_at desc", q.Sort)
+
+	_, err = b.Parse(url.Values{"sort": []string{"name", "-created_at", "updated_at"}})
+	require.IsType(t, &ParseError{}, err)
+	assert.Equal(t, "too many sort fields in query (max 2)", err.Error())
+	assert.Equal(t, CodeNotAllowed, err.(*ParseError).Code)
+
+	// unlimited by default.
+	_, err = MustNewBuilder(&Config{Model: model{}}).Parse(url.Values{"sort": []string{"name", "created_at", "updated_at"}})
+	assert.NoError(t, err)
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	type reading struct {
+		Temp  int     `query:"filter,split"`
+		Delta int64   `query:"filter"`
+		Ratio float64 `query:"filter"`
+		Count uint    `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: reading{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{name: "bare", params: url.Values{"temp": []string{"-5"}}, wantExp: "temp = ?", wantVal: []interface{}{-5}},
+		{name: "eq", params: url.Values{"temp_eq": []string{"-5"}}, wantExp: "temp = ?", wantVal: []interface{}{-5}},
+		{name: "neq", params: url.Values{"delta_neq": []string{"-5"}}, wantExp: "delta <> ?", wantVal: []interface{}{int64(-5)}},
+		{name: "lt", params: url.Values{"temp_lt": []string{"-5"}}, wantExp: "temp < ?", wantVal: []interface{}{-5}},
+		{name: "lte", params: url.Values{"delta_lte": []string{"-5"}}, wantExp: "delta <= ?", wantVal: []interface{}{int64(-5)}},
+		{name: "gt", params: url.Values{"ratio_gt": []string{"-0.5"}}, wantExp: "ratio > ?", wantVal: []interface{}{-0.5}},
+		{name: "gte", params: url.Values{"ratio_gte": []string{"-1e3"}}, wantExp: "ratio >= ?", wantVal: []interface{}{-1000.0}},
+		{name: "in", params: url.Values{"temp_in": []string{"-5,-10"}}, wantExp: "temp IN (?)", wantVal: []interface{}{[]int{-5, -10}}},
+		{name: "not in", params: url.Values{"delta_not_in": []string{"-5,10"}}, wantExp: "delta NOT IN (?)", wantVal: []interface{}{[]int64{-5, 10}}},
+		{name: "between", params: url.Values{"temp_between": []string{"-10,-5"}}, wantExp: "temp BETWEEN ? AND ?", wantVal: []interface{}{-10, -5}},
+		{name: "split", params: url.Values{"temp": []string{"-5,-10"}}, wantExp: "(temp = ? OR temp = ?)", wantVal: []interface{}{-5, -10}},
+		{name: "empty after split", params: url.Values{"temp": []string{"-5,"}}, wantErr: true},
+		{name: "empty in list", params: url.Values{"temp_in": []string{"-5,,-10"}}, wantErr: true},
+		{name: "sign only", params: url.Values{"temp_lt": []string{"-"}}, wantErr: true},
+		{name: "double sign", params: url.Values{"delta": []string{"--5"}}, wantErr: true},
+		{name: "unsigned", params: url.Values{"count_gt": []string{"-1"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+type tagNames string
+
+func (tagNames) Wrap(s string) string { return "(id IN (SELECT pet_id FROM tags WHERE " + s + "))" }
+
+func TestMultiAnd(t *testing.T) {
+	type pet struct {
+		Name string    `query:"filter"`
+		Tag  tagNames  `query:"filter,multi=and"`
+		Age  int       `query:"filter,multi=and"`
+		Born time.Time `query:"filter,multi=or"`
+	}
+	b := MustNewBuilder(&Config{Model: pet{}, Dialect: DialectPostgres, LikeAnyArray: true})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+	}{
+		{
+			name:    "or by default",
+			params:  url.Values{"name": []string{"a", "b"}},
+			wantExp: "(name = ? OR name = ?)",
+		},
+		{
+			name:    "and with wrapper",
+			params:  url.Values{"tag": []string{"red", "blue"}},
+			wantExp: "((id IN (SELECT pet_id FROM tags WHERE tag = ?)) AND (id IN (SELECT pet_id FROM tags WHERE tag = ?)))",
+		},
+		{
+			name:    "and without the any format",
+			params:  url.Values{"tag_like": []string{"red", "blue"}},
+			wantExp: "((id IN (SELECT pet_id FROM tags WHERE tag LIKE ?)) AND (id IN (SELECT pet_id FROM tags WHERE tag LIKE ?)))",
+		},
+		{
+			name:    "single value",
+			params:  url.Values{"tag": []string{"red"}},
+			wantExp: "(id IN (SELECT pet_id FROM tags WHERE tag = ?))",
+		},
+		{
+			name:    "between",
+			params:  url.Values{"age_between": []string{"1,10", "5,20"}},
+			wantExp: "(age BETWEEN ? AND ? AND age BETWEEN ? AND ?)",
+		},
+		{
+			name:    "explicit or",
+			params:  url.Values{"born_gt": []string{"2020-01-01T00:00:00Z", "2021-01-01T00:00:00Z"}},
+			wantExp: "(born > ? OR born > ?)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+		})
+	}
+	assert.Panics(t, func() {
+		NewBuilder(&Config{Model: struct {
+			Name string `query:"filter,multi=xor"`
+		}{}})
+	})
+}
+
+func TestGormColumn(t *testing.T) {
+	type user struct {
+		UserName string `query:"filter,sort,group" gorm:"column:usr_name"`
+		Email    string `query:"filter,column=mail" gorm:"type:varchar(100);COLUMN:usr_email"`
+		Nick     string `query:"filter,param=alias" gorm:"column:usr_nick"`
+		Age      int    `query:"filter" gorm:"not null"`
+	}
+	b := MustNewBuilder(&Config{Model: user{}, ExplicitSelect: true})
+	tests := []struct {
+		params  url.Values
+		wantExp string
+	}{
+		{params: url.Values{"user_name": []string{"a8m"}}, wantExp: "usr_name = ?"},
+		{params: url.Values{"email": []string{"a8m"}}, wantExp: "mail = ?"},
+		{params: url.Values{"alias_like": []string{"a8m"}}, wantExp: "usr_nick LIKE ?"},
+		{params: url.Values{"age": []string{"1"}}, wantExp: "age = ?"},
+	}
+	for _, tt := range tests {
+		q, err := b.Parse(tt.params)
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantExp, q.CondExp)
+	}
+	assert.Equal(t, []string{"usr_name", "mail", "usr_nick", "age"}, b.selectFields)
+	// the sort and the group keys are the field names, and they are resolved to their columns.
+	q, err := b.Parse(url.Values{"sort": []string{"-user_name"}, "group": []string{"user_name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "usr_name desc", q.Sort)
+	assert.Equal(t, []SortField{{Column: "usr_name", Desc: true}}, q.SortFields)
+	assert.Equal(t, "usr_name", q.GroupBy)
+}
+
+func TestNoSelectOption(t *testing.T) {
+	type doc struct {
+		Name    string `query:"filter,sort"`
+		Blob    string `query:"filter,noselect"`
+		Summary string `query:"detailed"`
+		Raw     string `query:"filter,detailed,noselect"`
+	}
+	tests := []struct {
+		config *Config
+		want   string
+	}{
+		{config: &Config{Model: doc{}, ExplicitSelect: true}, want: "name,summary"},
+		{config: &Config{Model: doc{}, OnlySelectNonDetailedFields: true}, want: "name"},
+	}
+	for _, tt := range tests {
+		q, err := MustNewBuilder(tt.config).Parse(url.Values{"blob_like": []string{"x"}, "raw": []string{"y"}})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, q.Select)
+		assert.Equal(t, "blob LIKE ? AND raw = ?", q.CondExp, "the fields are still filterable")
+	}
+}
+
+func TestBoolValues(t *testing.T) {
+	type device struct {
+		Active bool          `query:"filter"`
+		Online *sql.NullBool `query:"filter"`
+	}
+	values := map[string]bool{"yes": true, "no": false, "on": true, "off": false}
+	b := MustNewBuilder(&Config{Model: device{}, BoolValues: values})
+	tests := []struct {
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{params: url.Values{"active": []string{"yes"}}, wantExp: "active = ?", wantVal: []interface{}{"true"}},
+		{params: url.Values{"active_neq": []string{"off"}}, wantExp: "active <> ?", wantVal: []interface{}{"false"}},
+		{params: url.Values{"online": []string{"on"}}, wantExp: "online = ?", wantVal: []interface{}{"true"}},
+		{params: url.Values{"active": []string{"1"}}, wantExp: "active = ?", wantVal: []interface{}{"1"}},
+		{params: url.Values{"active": []string{"YES"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		q, err := b.Parse(tt.params)
+		if tt.wantErr {
+			assert.IsType(t, &ParseError{}, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantExp, q.CondExp)
+		assert.Equal(t, tt.wantVal, q.CondVal)
+	}
+	// the tokens are not accepted without the config.
+	_, err := MustNewBuilder(&Config{Model: device{}}).Parse(url.Values{"active": []string{"yes"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestRequireTaggedFields(t *testing.T) {
+	type untagged struct {
+		Name string
+		Age  int `json:"age"`
+	}
+	_, err := NewBuilder(&Config{Model: untagged{}, RequireTaggedFields: true})
+	assert.Equal(t, ErrNoTaggedFields, err)
+	_, err = NewBuilder(&Config{Model: model{}, TagName: "db", RequireTaggedFields: true})
+	assert.Equal(t, ErrNoTaggedFields, err)
+
+	_, err = NewBuilder(&Config{Model: untagged{}})
+	assert.NoError(t, err, "disabled by default")
+	_, err = NewBuilder(&Config{Model: struct {
+		Name string `query:"sort"`
+	}{}, RequireTaggedFields: true})
+	assert.NoError(t, err)
+}
+
+func TestSortNulls(t *testing.T) {
+	nulls := map[string]string{"updated_at": NullsLast, "created_at": NullsFirst}
+	tests := []struct {
+		name     string
+		config   *Config
+		wantSort string
+	}{
+		{
+			name:     "postgres",
+			config:   &Config{Model: model{}, Dialect: DialectPostgres, SortNulls: nulls},
+			wantSort: "updated_at desc NULLS LAST, created_at NULLS FIRST, name",
+		},
+		{
+			name:     "default dialect",
+			config:   &Config{Model: model{}, SortNulls: nulls},
+			wantSort: "updated_at desc NULLS LAST, created_at NULLS FIRST, name",
+		},
+		{
+			name:     "mysql",
+			config:   &Config{Model: model{}, Dialect: DialectMySQL, SortNulls: nulls},
+			wantSort: "updated_at desc, created_at, name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(url.Values{"sort": []string{"-updated_at", "created_at", "name"}})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSort, q.Sort)
+			assert.Equal(t, []SortField{{Column: "updated_at", Desc: true}, {Column: "created_at"}, {Column: "name"}}, q.SortFields)
+		})
+	}
+	_, err := NewBuilder(&Config{Model: model{}, SortNulls: map[string]string{"age": NullsLast}})
+	assert.Error(t, err, "age is not a sort field")
+	_, err = NewBuilder(&Config{Model: model{}, SortNulls: map[string]string{"name": "middle"}})
+	assert.Error(t, err)
+}
+
+func TestComputedSorts(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model: pet{},
+		ComputedSorts: map[string]ComputedSort{
+			"relevance": {Exp: "(age * ? + length(name))", Vals: []interface{}{2}},
+		},
+	})
+	q, err := b.Parse(url.Values{
+		"sort": []string{"-relevance", "name"},
+		"name": []string{"a8m"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "(age * ? + length(name)) desc, name", q.Sort)
+	assert.Equal(t, []interface{}{2}, q.SortVal)
+	assert.Equal(t, []SortField{{Column: "relevance", Desc: true}, {Column: "name"}}, q.SortFields)
+	// the sort arguments are bound after the condition arguments.
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name = $1) ORDER BY (age * $2 + length(name)) desc, name LIMIT 25`, captureSQL(t, q.Apply, "a8m", 2))
+
+	_, err = b.Parse(url.Values{"sort": []string{"score"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestSortExpressions(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:           pet{},
+		SortExpressions: map[string]string{"score": "age - length(name)"},
+		SortNulls:       map[string]string{"score": NullsLast},
+	})
+	q, err := b.Parse(url.Values{"sort": []string{"-score", "name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "(age - length(name)) desc NULLS LAST, name", q.Sort)
+	assert.Empty(t, q.SortVal)
+	assert.Equal(t, []SortField{{Column: "score", Desc: true}, {Column: "name"}}, q.SortFields)
+
+	_, err = b.Parse(url.Values{"sort": []string{"rank"}})
+	assert.IsType(t, &ParseError{}, err)
+
+	_, err = NewBuilder(&Config{
+		Model:           pet{},
+		ComputedSorts:   map[string]ComputedSort{"score": {Exp: "age"}},
+		SortExpressions: map[string]string{"score": "age"},
+	})
+	assert.EqualError(t, err, "query: sort expression 'score' is also a computed sort")
+}
+
+func TestNullOperator(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantErr bool
+	}{
+		{
+			name:    "is null",
+			params:  url.Values{"updated_at_null": []string{"true"}},
+			wantExp: "updated_at IS NULL",
+		},
+		{
+			name:    "is not null",
+			params:  url.Values{"flag_ptr_null": []string{"false"}},
+			wantExp: "flag_ptr IS NOT NULL",
+		},
+		{
+			name:    "string field with wrapper",
+			params:  url.Values{"tag_name_null": []string{"1"}},
+			wantExp: "(name IN (SELECT DISTINCT tag_name IN tags WHERE tag_name IS NULL))",
+		},
+		{
+			name:    "non boolean value",
+			params:  url.Values{"name_null": []string{"yes"}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple values",
+			params:  url.Values{"name_null": []string{"true", "false"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Empty(t, q.CondVal)
+		})
+	}
+
+	// the custom boolean values are accepted by the operator.
+	b = MustNewBuilder(&Config{Model: model{}, BoolValues: map[string]bool{"yes": true, "no": false}})
+	q, err := b.Parse(url.Values{"name_null": []string{"yes"}, "flag_ptr_null": []string{"no"}})
+	require.NoError(t, err)
+	assert.Equal(t, "flag_ptr IS NOT NULL AND name IS NULL", q.CondExp)
+}
+
+func TestBetweenOperator(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	from, to := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "dates",
+			params:  url.Values{"created_at_between": []string{"2020-01-01T00:00:00Z,2020-02-01T00:00:00Z"}},
+			wantExp: "created_at BETWEEN ? AND ?",
+			wantVal: []interface{}{from, to},
+		},
+		{
+			name:    "numbers",
+			params:  url.Values{"age_between": []string{"10,20"}},
+			wantExp: "age BETWEEN ? AND ?",
+			wantVal: []interface{}{int64(10), int64(20)},
+		},
+		{
+			name:    "multiple ranges",
+			params:  url.Values{"year_between": []string{"1990,1995", "2000,2005"}},
+			wantExp: "(year BETWEEN ? AND ? OR year BETWEEN ? AND ?)",
+			wantVal: []interface{}{1990, 1995, 2000, 2005},
+		},
+		{
+			name:    "one value",
+			params:  url.Values{"age_between": []string{"10"}},
+			wantErr: true,
+		},
+		{
+			name:    "three values",
+			params:  url.Values{"age_between": []string{"10,20,30"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			params:  url.Values{"created_at_between": []string{"2020-01-01T00:00:00Z,tomorrow"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+	assert.Contains(t, b.UnknownParams(url.Values{"name_between": []string{"a,b"}}), "name_between")
+}
+
+func TestOpenAPIParameters(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	params := OpenAPIParameters(b)
+	byName := make(map[string]spec.Parameter, len(params))
+	for _, p := range params {
+		assert.Equal(t, "query", p.In, p.Name)
+		byName[p.Name] = p
+	}
+	require.Len(t, byName, len(params), "parameter names should be unique")
+	assert.Equal(t, []string{"limit", "offset", "sort", "search"}, []string{params[0].Name, params[1].Name, params[2].Name, params[3].Name})
+
+	limit := byName["limit"]
+	assert.Equal(t, "integer", limit.Type)
+	assert.Equal(t, 25, limit.Default)
+	assert.Equal(t, float64(0), *limit.Minimum)
+	assert.Equal(t, float64(100), *limit.Maximum)
+
+	assert.Equal(t, "array", byName["sort"].Type)
+	assert.Contains(t, byName["sort"].Description, "created_at")
+
+	assert.Equal(t, "string", byName["name"].Type)
+	assert.Equal(t, "string", byName["name_like"].Type)
+	assert.Equal(t, "integer", byName["age_gt"].Type)
+	assert.Equal(t, "int64", byName["age_gt"].Format)
+	assert.Equal(t, "int64", byName["year_gt"].Format, "int is 64-bit")
+	assert.Equal(t, "date-time", byName["created_at_lte"].Format)
+	assert.Equal(t, "boolean", byName["flag"].Type)
+	assert.Equal(t, "boolean", byName["age_null"].Type)
+
+	in := byName["age_in"]
+	assert.Equal(t, "array", in.Type)
+	assert.Equal(t, "csv", in.CollectionFormat)
+	assert.Equal(t, "integer", in.Items.Type)
+
+	between := byName["created_at_between"]
+	assert.Equal(t, "array", between.Type)
+	assert.Equal(t, int64(2), *between.MinItems)
+	assert.Equal(t, int64(2), *between.MaxItems)
+	assert.Equal(t, "date-time", between.Items.Format)
+
+	// every registered filter is documented.
+	for name := range b.filterFields {
+		assert.Contains(t, byName, name)
+	}
+
+	// limits above the maximum are clamped, and therefore, they are valid.
+	params = OpenAPIParameters(MustNewBuilder(&Config{Model: model{}, ClampLimit: true}))
+	assert.Equal(t, "limit", params[0].Name)
+	assert.Nil(t, params[0].Maximum)
+
+	// the sql.Null* types are documented by the types of their values.
+	type nullable struct {
+		Nickname   sql.NullString  `query:"filter"`
+		Legs       sql.NullInt64   `query:"filter"`
+		Rank       *sql.NullInt32  `query:"filter"`
+		Weight     sql.NullFloat64 `query:"filter"`
+		Vaccinated sql.NullBool    `query:"filter"`
+		BornAt     sql.NullTime    `query:"filter"`
+	}
+	byName = make(map[string]spec.Parameter)
+	for _, p := range OpenAPIParameters(MustNewBuilder(&Config{Model: nullable{}})) {
+		byName[p.Name] = p
+	}
+	for name, want := range map[string][2]string{
+		"nickname":   {"string", ""},
+		"legs_gt":    {"integer", "int64"},
+		"rank":       {"integer", "int32"},
+		"weight_lt":  {"number", "double"},
+		"vaccinated": {"boolean", ""},
+		"born_at":    {"string", "date-time"},
+	} {
+		assert.Equal(t, want, [2]string{byName[name].Type, byName[name].Format}, name)
+	}
+}
+
+func TestShadowedEmbeddedFields(t *testing.T) {
+	type base struct {
+		ID   int
+		Name string `query:"filter,sort"`
+		Age  int    `query:"filter"`
+	}
+	type shadowing struct {
+		base
+		Name string `query:"filter,param=title"`
+	}
+	b := MustNewBuilder(&Config{Model: shadowing{}, ExplicitSelect: true})
+
+	// the outer field is used, and the embedded one is ignored.
+	assert.Contains(t, b.filterFields, "title")
+	assert.NotContains(t, b.filterFields, "name")
+	assert.False(t, b.sortFields["name"])
+	// promoted fields that are not shadowed are still registered.
+	assert.Contains(t, b.filterFields, "age")
+	assert.Equal(t, []string{"name", "id", "age"}, b.selectFields)
+
+	q, err := b.Parse(url.Values{"title": []string{"foo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "title = ?", q.CondExp)
+	_, err = b.Parse(url.Values{"sort": []string{"name"}})
+	assert.IsType(t, &ParseError{}, err)
+}
+
+func TestDebugString(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: pet{}})
+	q, err := b.Parse(url.Values{
+		"name":   []string{"o'neil"},
+		"sort":   []string{"-age"},
+		"offset": []string{"10"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * WHERE name = 'o''neil' ORDER BY age desc LIMIT 25 OFFSET 10", q.DebugString())
+	q, err = b.Parse(url.Values{"age_in": []string{"1,2"}})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * WHERE age IN (1, 2) LIMIT 25 OFFSET 0", q.DebugString())
+
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	q = &DBQuery{
+		Select:  "id, name",
+		CondExp: "created_at > ? AND deleted_at = ? AND active = ? AND ttl < ? AND tag IN (?)",
+		CondVal: []interface{}{at, (*time.Time)(nil), true, time.Second, []MyEnum{enumVal1, enumVal2}},
+		Sort:    "(age * ?) desc",
+		SortVal: []interface{}{2},
+		Limit:   5,
+	}
+	assert.Equal(t, "SELECT id, name WHERE created_at > '2020-01-01T00:00:00Z' AND deleted_at = NULL AND active = TRUE AND ttl < 1000000000 AND tag IN ('v1', 'v2') ORDER BY (age * 2) desc LIMIT 5 OFFSET 0", q.DebugString())
+	assert.Equal(t, "", (*DBQuery)(nil).DebugString())
+}
+
+func TestFloatFields(t *testing.T) {
+	type product struct {
+		Price  float64  `query:"filter,sort"`
+		Weight *float32 `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: product{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "float64",
+			params:  url.Values{"price_gte": []string{"9.99"}},
+			wantExp: "price >= ?",
+			wantVal: []interface{}{9.99},
+		},
+		{
+			name:    "float32 pointer",
+			params:  url.Values{"weight_lt": []string{"1.5"}},
+			wantExp: "weight < ?",
+			wantVal: []interface{}{1.5},
+		},
+		{
+			name:    "integer value",
+			params:  url.Values{"price": []string{"10"}},
+			wantExp: "price = ?",
+			wantVal: []interface{}{float64(10)},
+		},
+		{
+			name:    "invalid value",
+			params:  url.Values{"price_neq": []string{"cheap"}},
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			params:  url.Values{"price_lte": []string{"NaN"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			params:  url.Values{"weight_gt": []string{""}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+func TestUnsignedFields(t *testing.T) {
+	type account struct {
+		ID       uint64  `query:"filter,sort"`
+		Count    uint    `query:"filter"`
+		ParentID *uint64 `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{Model: account{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "uint64",
+			params:  url.Values{"id": []string{"18446744073709551615"}},
+			wantExp: "id = ?",
+			wantVal: []interface{}{uint64(18446744073709551615)},
+		},
+		{
+			name:    "uint",
+			params:  url.Values{"count_gt": []string{"3"}},
+			wantExp: "count > ?",
+			wantVal: []interface{}{uint(3)},
+		},
+		{
+			name:    "uint64 pointer",
+			params:  url.Values{"parent_id_in": []string{"1,2"}},
+			wantExp: "parent_id IN (?)",
+			wantVal: []interface{}{[]uint64{1, 2}},
+		},
+		{
+			name:    "negative value",
+			params:  url.Values{"id_gte": []string{"-1"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			params:  url.Values{"count": []string{"many"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:              model{},
+		BaseConditions:     []Condition{{Exp: "tenant_id = ?", Vals: []interface{}{42}}},
+		MultiColumnFilters: map[string][]ColumnFilter{"since": {{Column: "created_at", Op: "gte"}, {Column: "updated_at", Op: "gte"}}},
+	})
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	params := url.Values{
+		"status":        []string{"active"},
+		"age_between":   []string{"10,20"},
+		"name":          []string{"a8m", "pos"},
+		"year_gt":       []string{"1990"},
+		"flag":          []string{"true"},
+		"since":         []string{"2020-01-01T00:00:00Z"},
+		"age_in":        []string{"1,2"},
+		"name_null":     []string{"false"},
+		"created_at_lt": []string{"2020-01-01T00:00:00Z"},
+	}
+	wantExp := "age BETWEEN ? AND ? AND age IN (?) AND created_at < ? AND flag = ? AND (name = ? OR name = ?) AND name IS NOT NULL AND (created_at >= ? OR updated_at >= ?) AND status = ? AND year > ? AND tenant_id = ?"
+	wantVal := []interface{}{int64(10), int64(20), []int64{1, 2}, since, "true", "a8m", "pos", since, since, "active", 1990, 42}
+	for i := 0; i < 20; i++ {
+		q, err := b.Parse(params)
+		require.NoError(t, err)
+		require.Equal(t, wantExp, q.CondExp)
+		require.Equal(t, wantVal, q.CondVal)
+		require.Equal(t, strings.Count(q.CondExp, "?"), len(q.CondVal))
+	}
+}
+
+func TestDollarPlaceholder(t *testing.T) {
+	b := MustNewBuilder(&Config{
+		Model:          model{},
+		Placeholder:    PlaceholderDollar,
+		BaseConditions: []Condition{{Exp: "tenant_id = ?", Vals: []interface{}{42}}},
+		ComputedSorts:  map[string]ComputedSort{"relevance": {Exp: "similarity(name, ?)", Vals: []interface{}{"pet"}}},
+	})
+	q, err := b.Parse(url.Values{
+		"age_between": []string{"1,5"},
+		"name":        []string{"a8m", "pos"},
+		"search":      []string{"foo"},
+		"sort":        []string{"-relevance"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "age BETWEEN $1 AND $2 AND (name = $3 OR name = $4) AND tenant_id = $5 AND (name = $6 OR status LIKE $7)", q.CondExp)
+	assert.Equal(t, []interface{}{int64(1), int64(5), "a8m", "pos", 42, "foo", "%foo%"}, q.CondVal)
+	require.Len(t, q.Clauses, 4)
+	assert.Equal(t, "(name = $3 OR name = $4)", q.Clauses[1].Exp)
+	assert.Equal(t, "(name = $6 OR status LIKE $7)", q.Clauses[3].Exp)
+	assert.Equal(t, "similarity(name, $8) desc", q.Sort)
+	assert.Equal(t, "SELECT * WHERE age BETWEEN 1 AND 5 AND (name = 'a8m' OR name = 'pos') AND tenant_id = 42 AND (name = 'foo' OR status LIKE '%foo%') ORDER BY similarity(name, 'pet') desc LIMIT 25 OFFSET 0", q.DebugString())
+
+	_, err = NewBuilder(&Config{Model: model{}, Placeholder: ":"})
+	assert.Error(t, err)
+
+	// list values get a placeholder for each value, and the cursor is numbered after the where values.
+	b = MustNewBuilder(&Config{
+		Model:        pet{},
+		Placeholder:  PlaceholderDollar,
+		CursorField:  "age",
+		HavingFields: map[string]string{"pet_count": "COUNT(*)"},
+	})
+	q, err = b.Parse(url.Values{"name_in": []string{"a8m,pos"}, "age_not_in": []string{"1"}, "after": []string{"10"}})
+	require.NoError(t, err)
+	assert.Equal(t, "age NOT IN ($1) AND name IN ($2, $3)", q.CondExp)
+	assert.Equal(t, []interface{}{1, "a8m", "pos"}, q.CondVal)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age NOT IN ($1) AND name IN ($2, $3)) AND (age > $4) ORDER BY age asc LIMIT 25`, captureSQL(t, q.Apply, 1, "a8m", "pos", 10))
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (age NOT IN ($1)) AND (name IN ($2, $3)) AND (age > $4) ORDER BY age asc LIMIT 25`, captureSQL(t, q.ApplyStructured, 1, "a8m", "pos", 10))
+	assert.Equal(t, "SELECT * WHERE age NOT IN (1) AND name IN ('a8m', 'pos') AND age > 10 ORDER BY age asc LIMIT 25 OFFSET 0", q.DebugString())
+
+	// the having values are bound after the cursor, and the count query doesn't bind the cursor.
+	q, err = b.Parse(url.Values{"name_in": []string{"a8m,pos"}, "pet_count_gt": []string{"1"}, "after": []string{"10"}})
+	require.NoError(t, err)
+	assert.Equal(t, "COUNT(*) > $4", q.HavingExp)
+	assert.Equal(t, `SELECT * FROM "pets"  WHERE (name IN ($1, $2)) AND (age > $3) HAVING (COUNT(*) > $4) ORDER BY age asc LIMIT 25`, captureSQL(t, q.Apply, "a8m", "pos", 10, 1.0))
+	count := func(db *gorm.DB) *gorm.DB { return q.ApplyCount(db.Model(&pet{})) }
+	assert.Equal(t, `SELECT count(*) FROM (SELECT * FROM "pets"  WHERE (name IN ($1, $2)) HAVING (COUNT(*) > $3)) AS count_table`, strings.TrimSpace(captureSQL(t, count, "a8m", "pos", 1.0)))
+}
+
+func TestFieldParsers(t *testing.T) {
+	type version struct{ Major, Minor int }
+	type job struct {
+		Status  string  `query:"filter"`
+		Timeout int64   `query:"filter"`
+		Version version `query:"filter"`
+	}
+	b := MustNewBuilder(&Config{
+		Model: job{},
+		FieldParsers: map[string]func(string) (interface{}, bool){
+			"status": func(s string) (interface{}, bool) {
+				return s, s == "active" || s == "idle"
+			},
+			"timeout": func(s string) (interface{}, bool) {
+				d, err := time.ParseDuration(s)
+				return int64(d), err == nil
+			},
+			"version": func(s string) (interface{}, bool) {
+				var major, minor int
+				_, err := fmt.Sscanf(s, "%d.%d", &major, &minor)
+				return major*1000 + minor, err == nil
+			},
+		},
+	})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "valid enum",
+			params:  url.Values{"status_in": []string{"active,idle"}},
+			wantExp: "status IN (?)",
+			wantVal: []interface{}{[]string{"active", "idle"}},
+		},
+		{
+			name:    "invalid enum",
+			params:  url.Values{"status": []string{"running"}},
+			wantErr: true,
+		},
+		{
+			name:    "pattern operators use the default parser",
+			params:  url.Values{"status_like": []string{"act"}},
+			wantExp: "status LIKE ?",
+			wantVal: []interface{}{"%act%"},
+		},
+		{
+			name:    "transformed value",
+			params:  url.Values{"timeout_gt": []string{"1h30m"}},
+			wantExp: "timeout > ?",
+			wantVal: []interface{}{int64(90 * time.Minute)},
+		},
+		{
+			name:    "invalid transformed value",
+			params:  url.Values{"timeout_gt": []string{"90"}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type with a parser",
+			params:  url.Values{"version_gte": []string{"1.2"}},
+			wantExp: "version >= ?",
+			wantVal: []interface{}{1002},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+
+	// the parsers are keyed by the column of the struct field, and not by its param name.
+	type task struct {
+		Status string `query:"filter,param=state"`
+	}
+	b = MustNewBuilder(&Config{
+		Model: task{},
+		FieldParsers: map[string]func(string) (interface{}, bool){
+			"status": func(s string) (interface{}, bool) { return s, s == "active" },
+		},
+	})
+	_, err := b.Parse(url.Values{"state": []string{"bad"}})
+	assert.IsType(t, &ParseError{}, err)
+	q, err := b.Parse(url.Values{"state": []string{"active"}})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"active"}, q.CondVal)
+
+	_, err = NewBuilder(&Config{
+		Model:        task{},
+		FieldParsers: map[string]func(string) (interface{}, bool){"state": parseInt},
+	})
+	assert.EqualError(t, err, "query: field parser of unknown column 'state'")
+}
+
+func TestOperatorArity(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr string
+	}{
+		{
+			name:    "between with one value",
+			params:  url.Values{"age_between": []string{"10"}},
+			wantErr: "expect 2 value(s) for key 'age_between', got 1",
+		},
+		{
+			name:    "between with three values",
+			params:  url.Values{"age_between": []string{"10,20", "1,2,3"}},
+			wantErr: "expect 2 value(s) for key 'age_between', got 3",
+		},
+		{
+			name:    "in without values",
+			params:  url.Values{"age_in": []string{""}},
+			wantErr: "invalid parameter for key 'age_in'",
+		},
+		{
+			name:    "eq without a value",
+			params:  url.Values{"name": []string{""}},
+			wantErr: "invalid parameter for key 'name'",
+		},
+		{
+			name:    "null given twice",
+			params:  url.Values{"name_null": []string{"true", "false"}},
+			wantErr: "expect one value for key 'name_null', got 2",
+		},
+		{
+			name:   "valid values",
+			params: url.Values{"age_between": []string{"10,20"}, "age_in": []string{"1", "2,3"}, "name": []string{"a", "b"}, "name_null": []string{"false"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.Parse(tt.params)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.IsType(t, &ParseError{}, err)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEnumFields(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: model{}})
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+		wantErr bool
+	}{
+		{
+			name:    "valid value",
+			params:  url.Values{"enum_val_eq": []string{"v1"}},
+			wantExp: "enum_val = ?",
+			wantVal: []interface{}{"v1"},
+		},
+		{
+			name:    "invalid value",
+			params:  url.Values{"enum_val_eq": []string{"bogus"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pointer value",
+			params:  url.Values{"enum_val_ptr": []string{"v1", "bogus"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid list",
+			params:  url.Values{"enum_val_ptr_in": []string{"v1,v2"}},
+			wantExp: "enum_val_ptr IN (?)",
+			wantVal: []interface{}{[]MyEnum{enumVal1, enumVal2}},
+		},
+		{
+			name:    "invalid list",
+			params:  url.Values{"enum_val_not_in": []string{"v1,v3"}},
+			wantErr: true,
+		},
+		{
+			name:    "patterns are not validated",
+			params:  url.Values{"enum_val_like": []string{"v"}},
+			wantExp: "enum_val LIKE ?",
+			wantVal: []interface{}{"%v%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			if tt.wantErr {
+				assert.IsType(t, &ParseError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+func TestBareRangeForTime(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		config  *Config
+		params  url.Values
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "one value",
+			config:  &Config{Model: model{}, BareRangeForTime: true},
+			params:  url.Values{"created_at": []string{"2023-01-01T00:00:00Z"}},
+			wantExp: "created_at = ?",
+			wantVal: []interface{}{from},
+		},
+		{
+			name:    "two values",
+			config:  &Config{Model: model{}, BareRangeForTime: true},
+			params:  url.Values{"created_at": []string{"2023-01-01T00:00:00Z", "2023-02-01T00:00:00Z"}},
+			wantExp: "created_at BETWEEN ? AND ?",
+			wantVal: []interface{}{from, to},
+		},
+		{
+			name:    "two values of eq operator",
+			config:  &Config{Model: model{}, BareRangeForTime: true},
+			params:  url.Values{"created_at_eq": []string{"2023-01-01T00:00:00Z", "2023-02-01T00:00:00Z"}},
+			wantExp: "(created_at = ? OR created_at = ?)",
+			wantVal: []interface{}{from, to},
+		},
+		{
+			name:    "two values of non-time field",
+			config:  &Config{Model: model{}, BareRangeForTime: true},
+			params:  url.Values{"age": []string{"1", "2"}},
+			wantExp: "(age = ? OR age = ?)",
+			wantVal: []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:    "two values without the option",
+			config:  &Config{Model: model{}},
+			params:  url.Values{"created_at": []string{"2023-01-01T00:00:00Z", "2023-02-01T00:00:00Z"}},
+			wantExp: "(created_at = ? OR created_at = ?)",
+			wantVal: []interface{}{from, to},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := MustNewBuilder(tt.config).Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
+
+type address struct {
+	ID   int
+	City string `query:"filter"`
+}
+
+type owner struct {
+	ID        int
+	Name      string `query:"filter"`
+	AddressID int
+	Address   *address `query:"filter"`
+}
+
+type ownedPet struct {
+	ID      int
+	Name    string `query:"filter,sort"`
+	OwnerID int
+	Owner   owner     `query:"filter"`
+	Vet     *owner    `query:"filter,param=doctor" gorm:"foreignkey:VetRefer"`
+	At      time.Time `query:"filter"`
+}
+
+func (ownedPet) TableName() string { return "pets" }
+
+func TestJoinFilters(t *testing.T) {
+	b := MustNewBuilder(&Config{Model: ownedPet{}, ExplicitSelect: true})
+	tests := []struct {
+		name      string
+		params    url.Values
+		wantExp   string
+		wantVal   []interface{}
+		wantJoins []string
+	}{
+		{
+			name:      "joined field",
+			params:    url.Values{"owner.name_like": []string{"bob"}, "name": []string{"rex"}},
+			wantExp:   "pets.name = ? AND owners.name LIKE ?",
+			wantVal:   []interface{}{"rex", "%bob%"},
+			wantJoins: []string{"JOIN owners ON owners.id = pets.owner_id"},
+		},
+		{
+			name:    "nested joined field",
+			params:  url.Values{"owner.address.city": []string{"tlv"}, "owner.name": []string{"bob"}},
+			wantExp: "addresses.city = ? AND owners.name = ?",
+			wantVal: []interface{}{"tlv", "bob"},
+			wantJoins: []string{
+				"JOIN owners ON owners.id = pets.owner_id",
+				"JOIN addresses ON addresses.id = owners.address_id",
+			},
+		},
+		{
+			name:      "custom param and foreign key",
+			params:    url.Values{"doctor.name": []string{"dolittle"}},
+			wantExp:   "owners.name = ?",
+			wantVal:   []interface{}{"dolittle"},
+			wantJoins: []string{"JOIN owners ON owners.id = pets.vet_refer"},
+		},
+		{
+			name:    "no joins",
+			params:  url.Values{"name": []string{"rex"}},
+			wantExp: "name = ?",
+			wantVal: []interface{}{"rex"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+			assert.Equal(t, tt.wantJoins, q.Joins)
+		})
+	}
+	// relations are not selected, and are not registered as filters.
+	assert.Equal(t, []string{"id", "name", "owner_id", "at"}, b.selectFields)
+	assert.Contains(t, b.UnknownParams(url.Values{"owner": []string{"1"}, "owner_null": []string{"true"}}), "owner")
+
+	b = MustNewBuilder(&Config{Model: ownedPet{}})
+	q, err := b.ParseWithColumnRewriter(url.Values{"owner.name": []string{"bob"}, "name": []string{"rex"}}, func(c string) string { return "pets." + c })
+	require.NoError(t, err)
+	assert.Equal(t, "pets.name = ? AND owners.name = ?", q.CondExp, "joined columns are not rewritten")
+	assert.Equal(t, `SELECT "pets".* FROM "pets" JOIN owners ON owners.id = pets.owner_id WHERE (pets.name = $1 AND owners.name = $2) LIMIT 25`, captureSQL(t, q.Apply, "rex", "bob"))
+
+	// the columns of the model are qualified, since both tables have the "name" column.
+	b = MustNewBuilder(&Config{Model: ownedPet{}, ExplicitSelect: true, DefaultSort: "name"})
+	q, err = b.Parse(url.Values{"owner.name": []string{"bob"}, "name": []string{"rex"}})
+	require.NoError(t, err)
+	assert.Equal(t, "pets.name = ? AND owners.name = ?", q.CondExp)
+	assert.Equal(t, "pets.name", q.Sort)
+	assert.Equal(t, "pets.id,pets.name,pets.owner_id,pets.at", q.Select)
+	q, err = b.Parse(url.Values{"owner.name": []string{"bob"}, "sort": []string{"-name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "pets.name desc", q.Sort)
+	q, err = b.Parse(url.Values{"name": []string{"rex"}, "sort": []string{"-name"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name = ?", q.CondExp, "columns are not qualified without joins")
+	assert.Equal(t, "name desc", q.Sort)
+
+	b = MustNewBuilder(&Config{Model: ownedPet{}, JoinSeparator: "__"})
+	q, err = b.Parse(url.Values{"owner__name": []string{"bob"}})
+	require.NoError(t, err)
+	assert.Equal(t, "owners.name = ?", q.CondExp)
+}
+
+func TestNilInterfaceField(t *testing.T) {
+	type model struct {
+		Name  string `query:"filter"`
+		Extra interface{}
+	}
+	b := MustNewBuilder(&Config{Model: model{}})
+	q, err := b.Parse(url.Values{"name": []string{"a8m"}})
+	require.NoError(t, err)
+	assert.Equal(t, "name = ?", q.CondExp)
+}
+
+func TestJoinLimits(t *testing.T) {
+	nested := url.Values{"owner.address.city": []string{"tlv"}}
+	b := MustNewBuilder(&Config{Model: ownedPet{}, MaxJoinDepth: 1})
+	_, err := b.Parse(url.Values{"owner.name": []string{"bob"}})
+	require.NoError(t, err)
+	_, err = b.Parse(nested)
+	assert.IsType(t, &ParseError{}, err)
+
+	b = MustNewBuilder(&Config{Model: ownedPet{}, MaxJoins: 2})
+	q, err := b.Parse(url.Values{"owner.name": []string{"bob"}, "owner.address.city": []string{"tlv"}})
+	require.NoError(t, err)
+	assert.Len(t, q.Joins, 2, "shared joins are counted once")
+	_, err = b.Parse(url.Values{"owner.address.city": []string{"tlv"}, "doctor.name": []string{"dolittle"}})
+	assert.IsType(t, &ParseError{}, err)
+
+	// zero means no limit.
+	q, err = MustNewBuilder(&Config{Model: ownedPet{}}).Parse(nested)
+	require.NoError(t, err)
+	assert.Len(t, q.Joins, 2)
+}
+
+type rolesKey struct{}
+
+func TestRLSApply(t *testing.T) {
+	roles := func(ctx context.Context) []string {
+		roles, _ := ctx.Value(rolesKey{}).([]string)
+		return roles
+	}
+	policies := []RLSPolicy{
+		{Scope: "member", Condition: Condition{Exp: "owner_id = ?", Vals: []interface{}{7}}},
+		{Scope: "admin", Condition: Condition{Exp: "1 = 1"}},
+		{Condition: Condition{Exp: "deleted_at IS NULL"}},
+	}
+	b := MustNewBuilder(&Config{Model: pet{}})
+	tests := []struct {
+		name    string
+		roles   []string
+		wantExp string
+		wantVal []interface{}
+	}{
+		{
+			name:    "restricted role",
+			roles:   []string{"member"},
+			wantExp: "name = ? AND owner_id = ? AND deleted_at IS NULL",
+			wantVal: []interface{}{"a8m", 7},
+		},
+		{
+			name:    "other role",
+			roles:   []string{"admin"},
+			wantExp: "name = ? AND 1 = 1 AND deleted_at IS NULL",
+			wantVal: []interface{}{"a8m"},
+		},
+		{
+			name:    "anonymous",
+			wantExp: "name = ? AND deleted_at IS NULL",
+			wantVal: []interface{}{"a8m"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := b.Parse(url.Values{"name": []string{"a8m"}})
+			require.NoError(t, err)
+			RLSApply(context.WithValue(context.Background(), rolesKey{}, tt.roles), q, policies, roles)
+			assert.Equal(t, tt.wantExp, q.CondExp)
+			assert.Equal(t, tt.wantVal, q.CondVal)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package query
+
+import "context"
+
+// RLSPolicy is a row-level security policy. the condition of the policy is added to the
+// query, if the authenticated user has the policy scope. an empty scope matches all users.
+// the OptOutParam of the condition is ignored, since clients can't opt-out of a policy.
+type RLSPolicy struct {
+	Scope string
+	Condition
+}
+
+// RLSApply adds the conditions of the policies that match the roles of the authenticated
+// user to the query, with AND condition. the query package doesn't know the auth layer of
+// the application, and therefore, roles returns the roles of the user from the request
+// context. for example:
+//
+//	query.RLSApply(ctx, q, policies, func(ctx context.Context) []string {
+//		if u := auth.FromContext(ctx); u != nil {
+//			return []string{u.Role}
+//		}
+//		return nil
+//	})
+func RLSApply(ctx context.Context, q *DBQuery, policies []RLSPolicy, roles func(context.Context) []string) {
+	scopes := make(map[string]bool)
+	for _, role := range roles(ctx) {
+		scopes[role] = true
+	}
+	for _, p := range policies {
+		if p.Scope == "" || scopes[p.Scope] {
+			q.And(p.Exp, p.Vals...)
+		}
+	}
+}